@@ -0,0 +1,61 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// --- Aiming ---
+
+const (
+	stickDeadzone = 0.25
+	stickReach    = 120.0 // how far from the player a full stick tilt aims
+)
+
+var crosshairColor = color.RGBA{255, 255, 255, 200}
+
+// Muzzle returns where shots heading along (dirX, dirY) leave the player.
+func (p *Player) Muzzle(dirX, dirY float64) (float64, float64) {
+	return p.X + p.Size/2 + dirX*p.Size/2, p.Y + p.Size/2 + dirY*p.Size/2
+}
+
+// aimDirection returns the unit vector the player is shooting along. Classic
+// mode always fires straight down the screen; twin-stick mode fires toward the
+// aim point.
+func (g *Game) aimDirection(p *Player, in Input) (float64, float64) {
+	if !g.twinStick {
+		return 0, 1
+	}
+	dx := in.AimX - (p.X + p.Size/2)
+	dy := in.AimY - (p.Y + p.Size/2)
+	length := math.Sqrt(dx*dx + dy*dy)
+	if length < 1 {
+		return 0, 1
+	}
+	return dx / length, dy / length
+}
+
+// drawCrosshair marks each player's aim point in twin-stick mode.
+func (g *Game) drawCrosshair(screen *ebiten.Image) {
+	if !g.twinStick || g.spectator != nil {
+		return
+	}
+	for i, p := range g.players.Items {
+		if p.Out {
+			continue
+		}
+		clr := crosshairColor
+		if g.players.Len() > 1 {
+			clr = p.Color
+		}
+		x, y := float32(g.lastInputs[i].AimX), float32(g.lastInputs[i].AimY)
+		vector.StrokeCircle(screen, x, y, 8, 1.5, clr, true)
+		vector.StrokeLine(screen, x-12, y, x-4, y, 1.5, clr, true)
+		vector.StrokeLine(screen, x+4, y, x+12, y, 1.5, clr, true)
+		vector.StrokeLine(screen, x, y-12, x, y-4, 1.5, clr, true)
+		vector.StrokeLine(screen, x, y+4, x, y+12, 1.5, clr, true)
+	}
+}
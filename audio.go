@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+	raudio "github.com/hajimehoshi/ebiten/v2/examples/resources/audio"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// --- Audio ---
+
+const (
+	sampleRate     = 44100
+	musicFade      = 90 // frames to crossfade between tracks
+	muteKey        = ebiten.KeyM
+	soundRepeatGap = 3 // frames before the same sound can start again
+)
+
+// audioFile holds the volume settings between runs.
+var audioFile = "audio.json"
+
+// soundNames are the sound effects. They and the music tracks, music_menu
+// and music_game, can be replaced by WAV or OGG files of the same name in
+// assets/audio, e.g. assets/audio/explosion.ogg.
+var soundNames = []string{"shoot", "enemy_shoot", "hit", "explosion", "pickup", "click", "death"}
+
+// AudioSettings are the player's volume choices, each 0-1.
+type AudioSettings struct {
+	Master float64 `json:"master"`
+	Music  float64 `json:"music"`
+	SFX    float64 `json:"sfx"`
+	Muted  bool    `json:"muted"`
+}
+
+var defaultAudioSettings = AudioSettings{Master: 0.8, Music: 0.6, SFX: 0.8}
+
+func loadAudioSettings() AudioSettings {
+	s := defaultAudioSettings
+	if data, err := os.ReadFile(audioFile); err == nil {
+		json.Unmarshal(data, &s)
+	}
+	return s
+}
+
+func saveAudioSettings(s AudioSettings) {
+	f, err := os.Create(audioFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	json.NewEncoder(f).Encode(s)
+}
+
+// Audio plays the sound effects and crossfades the looping music. A nil
+// *Audio is silent, which is how tests run the game. Sounds that fail to load
+// are logged and skipped; Ebiten opens the sound device itself on the first
+// frame and reports a failure there from RunGame.
+type Audio struct {
+	Settings AudioSettings
+
+	ctx    *audio.Context
+	sounds map[string][]byte // decoded PCM
+	last   map[string]int    // frame each sound last started
+	frame  int
+
+	// music is the track playing; fading is the one it's replacing
+	music, fading *audio.Player
+	musicName     string
+	fade          float64 // crossfade progress, 0-1
+}
+
+func NewAudio(settings AudioSettings) *Audio {
+	a := &Audio{
+		Settings: settings,
+		ctx:      audio.NewContext(sampleRate),
+		sounds:   map[string][]byte{},
+		last:     map[string]int{},
+		fade:     1,
+	}
+	for _, name := range soundNames {
+		pcm, err := a.loadSound(name)
+		if err != nil {
+			log.Printf("sound %s: %v", name, err)
+			continue
+		}
+		a.sounds[name] = pcm
+	}
+	return a
+}
+
+// assetStream decodes assets/audio/<name>.wav or .ogg, whichever is found.
+func (a *Audio) assetStream(name string) (io.ReadSeeker, int64, bool, error) {
+	for _, ext := range []string{".wav", ".ogg"} {
+		data, err := os.ReadFile(filepath.Join(spriteDir, "audio", name+ext))
+		if err != nil {
+			continue
+		}
+		s, n, err := decodeAudio(ext, data)
+		return s, n, true, err
+	}
+	return nil, 0, false, nil
+}
+
+func decodeAudio(ext string, data []byte) (io.ReadSeeker, int64, error) {
+	if ext == ".ogg" {
+		s, err := vorbis.DecodeWithSampleRate(sampleRate, bytes.NewReader(data))
+		if err != nil {
+			return nil, 0, err
+		}
+		return s, s.Length(), nil
+	}
+	s, err := wav.DecodeWithSampleRate(sampleRate, bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+	return s, s.Length(), nil
+}
+
+// loadSound reads a sound from the assets, falling back to the built-in one.
+func (a *Audio) loadSound(name string) ([]byte, error) {
+	s, _, ok, err := a.assetStream(name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return defaultSound(name)
+	}
+	return io.ReadAll(s)
+}
+
+// openMusic starts a looping player for a track.
+func (a *Audio) openMusic(name string) (*audio.Player, error) {
+	s, n, ok, err := a.assetStream(name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if s, n, err = defaultMusic(name); err != nil {
+			return nil, err
+		}
+	}
+	return a.ctx.NewPlayer(audio.NewInfiniteLoop(s, n))
+}
+
+func (a *Audio) volume(part float64) float64 {
+	if a.Settings.Muted {
+		return 0
+	}
+	return a.Settings.Master * part
+}
+
+// Play starts a sound effect. Repeats of a sound within soundRepeatGap frames
+// are dropped, so a volley of bullets doesn't stack up into noise.
+func (a *Audio) Play(name string) {
+	if a == nil || a.Settings.Muted {
+		return
+	}
+	pcm, ok := a.sounds[name]
+	if last, played := a.last[name]; !ok || (played && a.frame-last < soundRepeatGap) {
+		return
+	}
+	a.last[name] = a.frame
+	p := a.ctx.NewPlayerFromBytes(pcm)
+	p.SetVolume(a.volume(a.Settings.SFX))
+	p.Play()
+}
+
+// PlayMusic crossfades to a track. Asking for the track already playing does
+// nothing.
+func (a *Audio) PlayMusic(name string) {
+	if a == nil || a.musicName == name {
+		return
+	}
+	a.musicName = name
+	p, err := a.openMusic(name)
+	if err != nil {
+		log.Printf("music %s: %v", name, err)
+		p = nil
+	}
+	if a.fading != nil {
+		a.fading.Close()
+	}
+	a.fading, a.music, a.fade = a.music, p, 0
+	if a.music != nil {
+		a.music.SetVolume(0)
+		a.music.Play()
+	}
+}
+
+// Update advances the crossfade and applies volume changes. It runs once per
+// frame.
+func (a *Audio) Update() {
+	if a == nil {
+		return
+	}
+	a.frame++
+	a.fade = min(1, a.fade+1.0/musicFade)
+	vol := a.volume(a.Settings.Music)
+	if a.music != nil {
+		a.music.SetVolume(vol * a.fade)
+	}
+	if a.fading != nil {
+		if a.fade >= 1 {
+			a.fading.Close()
+			a.fading = nil
+		} else {
+			a.fading.SetVolume(vol * (1 - a.fade))
+		}
+	}
+}
+
+// --- Game Audio ---
+
+// playSound plays an effect for something that happened in the simulation,
+// but not again when rollback replays it.
+func (g *Game) playSound(name string) {
+	if !g.resimulating {
+		g.audio.Play(name)
+	}
+}
+
+// updateAudio picks the music for the current screen, clicks on menu
+// clicks and handles the mute key.
+func (g *Game) updateAudio() {
+	if g.audio == nil {
+		return
+	}
+	playing := g.gameState == "playing" || g.gameState == "spectate"
+	typing := g.gameState == "menu" || g.gameState == "lobby" || g.customInput
+	if !typing && inpututil.IsKeyJustPressed(muteKey) {
+		g.audio.Settings.Muted = !g.audio.Settings.Muted
+		saveAudioSettings(g.audio.Settings)
+	}
+	if !playing && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		g.audio.Play("click")
+	}
+	if playing {
+		g.audio.PlayMusic("music_game")
+	} else {
+		g.audio.PlayMusic("music_menu")
+	}
+	g.audio.Update()
+}
+
+// --- Built-in Sounds ---
+
+// defaultSound is the built-in version of a sound effect: Ebiten's example jab
+// for hits, and simple synthesized blips and bursts for the rest.
+func defaultSound(name string) ([]byte, error) {
+	r := rand.New(rand.NewSource(1))
+	switch name {
+	case "hit":
+		s, err := wav.DecodeWithSampleRate(sampleRate, bytes.NewReader(raudio.Jab_wav))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(s)
+	case "shoot":
+		return synth(0.08, func(t, p float64) float64 {
+			return 0.25 * square(sweep(t, 0.08, 900, 450)) * (1 - p)
+		}), nil
+	case "enemy_shoot":
+		return synth(0.12, func(t, p float64) float64 {
+			return 0.2 * math.Sin(2*math.Pi*sweep(t, 0.12, 360, 200)) * (1 - p)
+		}), nil
+	case "explosion":
+		lp := 0.0
+		return synth(0.45, func(t, p float64) float64 {
+			lp += (r.Float64()*2 - 1 - lp) * (0.35 - 0.3*p) // Darkens as it fades
+			return 0.7 * lp * (1 - p) * (1 - p)
+		}), nil
+	case "pickup":
+		return synth(0.18, func(t, p float64) float64 {
+			notes := [3]float64{660, 880, 1320}
+			return 0.2 * square(t*notes[min(2, int(p*3))]) * (1 - p*0.5)
+		}), nil
+	case "click":
+		return synth(0.03, func(t, p float64) float64 {
+			return 0.2 * math.Sin(2*math.Pi*1200*t) * (1 - p)
+		}), nil
+	case "death":
+		return synth(0.8, func(t, p float64) float64 {
+			saw := math.Mod(sweep(t, 0.8, 420, 50), 1)*2 - 1
+			return (0.25*saw + 0.2*(r.Float64()*2-1)*p) * (1 - p)
+		}), nil
+	}
+	return nil, fmt.Errorf("no built-in sound")
+}
+
+// defaultMusic is the built-in version of a track: Ebiten's example ragtime
+// on the menus and a synthesized bass and arpeggio loop in game.
+func defaultMusic(name string) (io.ReadSeeker, int64, error) {
+	if name == "music_menu" {
+		return decodeAudio(".ogg", raudio.Ragtime_ogg)
+	}
+	const beat = 0.25                           // seconds per note
+	bass := [4]float64{55, 55, 65.41, 49}       // A1 A1 C2 G1, one per bar
+	arp := [4]float64{220, 261.63, 329.63, 392} // A3 C4 E4 G4
+	pcm := synth(beat*16*4, func(t, p float64) float64 {
+		step := int(t / beat)
+		into := t - float64(step)*beat
+		root := bass[step/16%4]
+		note := arp[step%4] * root / 55
+		env := math.Exp(-into * 12)
+		return 0.18*math.Sin(2*math.Pi*root*t) + 0.07*square(note*t)*env
+	})
+	return bytes.NewReader(pcm), int64(len(pcm)), nil
+}
+
+// synth renders seconds of 16-bit stereo PCM. wave gets the time and the
+// fraction of the sound played, and returns a sample in -1 to 1.
+func synth(seconds float64, wave func(t, p float64) float64) []byte {
+	n := int(seconds * sampleRate)
+	pcm := make([]byte, n*4)
+	for i := range n {
+		t := float64(i) / sampleRate
+		v := int16(max(-1, min(1, wave(t, float64(i)/float64(n)))) * math.MaxInt16)
+		binary.LittleEndian.PutUint16(pcm[i*4:], uint16(v))
+		binary.LittleEndian.PutUint16(pcm[i*4+2:], uint16(v))
+	}
+	return pcm
+}
+
+// sweep is the phase, in cycles, of a tone gliding from f0 to f1 Hz over
+// length seconds.
+func sweep(t, length, f0, f1 float64) float64 {
+	return f0*t + (f1-f0)*t*t/(2*length)
+}
+
+// square is a square wave at phase cycles.
+func square(cycles float64) float64 {
+	if math.Mod(cycles, 1) < 0.5 {
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// --- Parallax Background ---
+
+// backgroundFile optionally defines each stage's background layers, drawn
+// back to front. A stage is named after a game mode ("Endless", "Survival",
+// "Time Attack 2m"...), with "default" for any mode not listed:
+//
+//	{
+//	  "default": {"layers": [
+//	    {"image": "tile", "speed": 0.5, "direction": 270},
+//	    {"image": "debris.png", "speed": 2, "direction": 260, "alpha": 0.6, "repeat": "x"}
+//	  ]}
+//	}
+//
+// image is "tile" for the built-in tile, a PNG in assets/backgrounds, or one
+// of proceduralImages, generated from the run seed.
+var backgroundFile = "backgrounds.json"
+
+// bgLayerDef is one layer as written in the background file.
+type bgLayerDef struct {
+	Image     string   `json:"image"`
+	Speed     float64  `json:"speed"`     // pixels per tick at normal scroll speed
+	Direction float64  `json:"direction"` // degrees the layer moves toward: 90 is down the screen, 270 up
+	Alpha     *float64 `json:"alpha"`     // default 1
+	Scale     *float64 `json:"scale"`     // default 1
+	Repeat    string   `json:"repeat"`    // "both" (default), "x", "y" or "none"
+	Twinkle   float64  `json:"twinkle"`   // fraction of alpha that fades in and out, 0-1
+}
+
+type stageDef struct {
+	Layers []bgLayerDef `json:"layers"`
+}
+
+// stages holds the built-in backgrounds, overridden by backgroundFile.
+var stages = map[string]stageDef{
+	"default": {Layers: []bgLayerDef{{Image: "tile", Speed: 0.5, Direction: 270}}},
+}
+
+// bgImages caches layer images by name; "tile" is added at startup.
+var bgImages = map[string]*ebiten.Image{}
+
+func loadBackgrounds() {
+	data, err := os.ReadFile(backgroundFile)
+	if err != nil {
+		return // Use defaults
+	}
+	var defs map[string]stageDef
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return
+	}
+	for name, def := range defs {
+		stages[name] = def
+	}
+}
+
+// bgImage returns a layer's image, loading it the first time. Missing images
+// give nil and the layer is skipped.
+func bgImage(name string) *ebiten.Image {
+	if img, ok := bgImages[name]; ok {
+		return img
+	}
+	img, _ := loadPNG(filepath.Join(spriteDir, "backgrounds", name))
+	bgImages[name] = img
+	return img
+}
+
+type bgLayer struct {
+	img          *ebiten.Image
+	vx, vy       float64 // pixels per tick at normal speed
+	alpha, scale float64
+	twinkle      float64
+	phase        float64 // where in the twinkle cycle the layer starts
+	repeatX      bool
+	repeatY      bool
+	x, y         float64 // scroll offset
+}
+
+// Background is a stage's stack of scrolling layers. Every layer's speed is
+// scaled by a shared multiplier that can be changed during a run.
+type Background struct {
+	Stage     string
+	Seed      int64 // procedural layers are generated from this
+	layers    []*bgLayer
+	generated []*ebiten.Image // procedural images, freed with the background
+	speed     float64         // current multiplier
+	target    float64         // multiplier speed is easing toward
+	ticks     float64         // time scrolled, in default ticks
+	dt        float64         // length of the last tick, in default ticks
+}
+
+const (
+	speedEase     = 0.05 // fraction of the gap to the target speed closed per tick
+	twinklePeriod = 150  // ticks for a twinkling layer to fade out and back
+)
+
+func NewBackground(stage string, seed int64) *Background {
+	def, ok := stages[stage]
+	if !ok {
+		def = stages["default"]
+	}
+	bg := &Background{Stage: stage, Seed: seed, speed: 1, target: 1, dt: 1}
+	for i, l := range def.Layers {
+		// Each layer gets its own seed so repeated images differ
+		img := generateLayer(l.Image, seed^stringSeed(fmt.Sprint(i, l.Image)))
+		if img != nil {
+			bg.generated = append(bg.generated, img)
+		} else if img = bgImage(l.Image); img == nil {
+			continue
+		}
+		sin, cos := math.Sincos(l.Direction * math.Pi / 180)
+		layer := &bgLayer{
+			img:     img,
+			vx:      cos * l.Speed,
+			vy:      sin * l.Speed,
+			alpha:   1,
+			scale:   1,
+			twinkle: max(0, min(1, l.Twinkle)),
+			phase:   float64(i) * 0.37,
+			repeatX: l.Repeat != "y" && l.Repeat != "none",
+			repeatY: l.Repeat != "x" && l.Repeat != "none",
+		}
+		if l.Alpha != nil {
+			layer.alpha = *l.Alpha
+		}
+		if l.Scale != nil && *l.Scale > 0 {
+			layer.scale = *l.Scale
+		}
+		bg.layers = append(bg.layers, layer)
+	}
+	return bg
+}
+
+// Dispose frees the background's generated images.
+func (bg *Background) Dispose() {
+	for _, img := range bg.generated {
+		img.Deallocate()
+	}
+	bg.generated = nil
+}
+
+// SetSpeed sets the scroll speed multiplier the background eases toward.
+func (bg *Background) SetSpeed(mult float64) {
+	bg.target = mult
+}
+
+// Update scrolls every layer by one tick, dt default ticks long.
+func (bg *Background) Update(dt float64) {
+	bg.speed += (bg.target - bg.speed) * (1 - math.Pow(1-speedEase, dt))
+	bg.ticks += dt
+	bg.dt = dt
+	for _, l := range bg.layers {
+		w, h := l.size()
+		l.x = math.Mod(l.x+l.vx*bg.speed*dt, w)
+		l.y = math.Mod(l.y+l.vy*bg.speed*dt, h)
+	}
+}
+
+func (l *bgLayer) size() (w, h float64) {
+	b := l.img.Bounds()
+	return float64(b.Dx()) * l.scale, float64(b.Dy()) * l.scale
+}
+
+// Draw tiles each layer through the camera, over everything it shows, so
+// shake and zoom move the background with the playfield without uncovering
+// its edges. alpha is how far into the next tick to draw, for smooth
+// scrolling.
+func (bg *Background) Draw(screen *ebiten.Image, alpha float64, cam *Camera) {
+	vx0, vy0, vx1, vy1 := cam.Visible(float64(screen.Bounds().Dx()), float64(screen.Bounds().Dy()))
+	for _, l := range bg.layers {
+		layerAlpha := l.alpha
+		if l.twinkle > 0 {
+			t := (bg.ticks+alpha*bg.dt)/twinklePeriod + l.phase
+			layerAlpha *= 1 - l.twinkle*(0.5+0.5*math.Sin(2*math.Pi*t))
+		}
+		w, h := l.size()
+		x := l.x + l.vx*bg.speed*alpha*bg.dt
+		y := l.y + l.vy*bg.speed*alpha*bg.dt
+		// Start at the tile covering the top-left corner of the view and
+		// step across until the view is covered
+		x0, x1 := x, x+w
+		if l.repeatX {
+			x0, x1 = x+math.Floor((vx0-x)/w)*w, vx1
+		}
+		y0, y1 := y, y+h
+		if l.repeatY {
+			y0, y1 = y+math.Floor((vy0-y)/h)*h, vy1
+		}
+		for ty := y0; ty < y1; ty += h {
+			for tx := x0; tx < x1; tx += w {
+				op := &ebiten.DrawImageOptions{}
+				if cam.moved {
+					op.Filter = ebiten.FilterLinear
+				}
+				op.GeoM.Scale(l.scale, l.scale)
+				op.GeoM.Translate(tx, ty)
+				op.GeoM.Concat(cam.geo)
+				op.ColorScale.ScaleAlpha(float32(layerAlpha))
+				screen.DrawImage(l.img, op)
+			}
+		}
+	}
+}
+
+// --- Stage Background ---
+
+// useStage switches to the stage's background unless it's already showing.
+// With the procedural setting on every stage uses proceduralStage, seeded by
+// the run and the stage so each looks different but a replay looks the same.
+func (g *Game) useStage(stage string) {
+	seed := g.seed ^ stringSeed(stage)
+	if g.proceduralBg {
+		stage = proceduralStage
+	}
+	if bg := g.background; bg == nil || bg.Stage != stage || (bg.Seed != seed && len(bg.generated) > 0) {
+		if bg != nil {
+			bg.Dispose()
+		}
+		g.background = NewBackground(stage, seed)
+	}
+}
+
+// updateBackground scrolls faster as the run's spawn rate climbs, and
+// surges while a bomb goes off.
+func (g *Game) updateBackground() {
+	speed := 1.0
+	d := g.difficulty
+	if span := d.SpawnStart - d.SpawnMin; span > 0 && g.spectator == nil {
+		speed += 0.75 * float64(d.SpawnStart-g.spawnInterval) / float64(span)
+	}
+	if g.bombFrames > 0 {
+		speed *= 2.5
+	}
+	g.background.SetSpeed(speed)
+	g.background.Update(g.tickScale())
+}
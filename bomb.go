@@ -0,0 +1,82 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// --- Bombs ---
+
+const (
+	startBombs       = 2
+	maxBombs         = 5
+	bombDamage       = 5
+	bombInvuln       = 90   // ticks of invulnerability after a bomb
+	bombEffectFrames = 40   // length of the shockwave effect
+	bombMilestone    = 1000 // score between free bomb charges
+)
+
+// detonateBomb clears enemy fire, damages every enemy on screen and makes the
+// player briefly invulnerable.
+func (g *Game) detonateBomb(p *Player) {
+	if p.Bombs <= 0 {
+		return
+	}
+	p.Bombs--
+	g.stats.BombsUsed++
+	p.Stats.BombsUsed++
+	g.enemyBullets.Clear()
+	for _, e := range g.enemies.Items {
+		if e.Gone || e.Y > float64(screenHeight) || e.Y+e.Size < 0 {
+			continue
+		}
+		if e.Hurt(bombDamage, g.ticks(hitFlash)) {
+			e.Gone = true
+			g.killEnemy(e, p)
+		}
+	}
+	p.Invuln = max(p.Invuln, g.ticks(bombInvuln))
+	g.bombFrames = g.ticks(bombEffectFrames)
+	g.shakeCamera(0.7)
+	g.bombX, g.bombY = p.X+p.Size/2, p.Y+p.Size/2
+}
+
+// addBomb grants a bomb charge, up to maxBombs.
+func (p *Player) addBomb() {
+	if p.Bombs < maxBombs {
+		p.Bombs++
+	}
+}
+
+// checkBombMilestone hands every player still in the run a bomb each time the
+// team score passes a multiple of bombMilestone.
+func (g *Game) checkBombMilestone() {
+	for g.score >= g.nextBombScore {
+		for _, p := range g.players.Items {
+			if !p.Out || p.Respawn > 0 {
+				p.addBomb()
+			}
+		}
+		g.nextBombScore += bombMilestone
+	}
+}
+
+// drawBombEffect draws the expanding shockwave and screen flash.
+func (g *Game) drawBombEffect(screen *ebiten.Image) {
+	if g.bombFrames <= 0 {
+		return
+	}
+	length := g.ticks(bombEffectFrames)
+	t := 1 - float32(g.bombFrames)/float32(length)
+	alpha := uint8(200 * (1 - t))
+	// Screen flash fades out quickly
+	if g.bombFrames > length-g.ticks(10) {
+		vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.NRGBA{255, 255, 255, alpha / 2}, false)
+	}
+	radius := t * screenWidth
+	cx, cy := float32(g.bombX), float32(g.bombY)
+	vector.StrokeCircle(screen, cx, cy, radius, 12*(1-t)+2, color.NRGBA{255, 240, 180, alpha}, true)
+	vector.StrokeCircle(screen, cx, cy, radius*0.7, 6*(1-t)+1, color.NRGBA{255, 160, 60, alpha}, true)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// --- Camera ---
+
+const (
+	traumaDecay   = 0.025 // trauma lost per default tick
+	maxShakeShift = 14.0  // pixels at full trauma and full shake setting
+	maxShakeAngle = 0.04  // radians at full trauma and full shake setting
+	followEase    = 0.12  // fraction of the gap to the target closed per default tick
+	hitStopKill   = 3     // ticks the game freezes on a kill
+)
+
+// zoomLevels are the choices for the camera zoom setting. Zoomed in, the
+// camera follows the players around the playfield.
+var zoomLevels = []float64{1, 1.25, 1.5}
+
+// CameraSettings are the player's camera choices.
+type CameraSettings struct {
+	Shake   bool
+	Amount  float64 // shake strength, 0-1
+	HitStop bool
+	Zoom    int // index into zoomLevels
+}
+
+var defaultCameraSettings = CameraSettings{Shake: true, Amount: 0.6, HitStop: true}
+
+const (
+	worldLayer = iota // the playfield
+	glowLayer         // bloom's copy of the bullets
+	numCameraLayers
+)
+
+// Camera is the view of the playfield. All world drawing goes through it:
+// it follows a point, zooms around it and shakes with trauma, which hits and
+// explosions add and which fades over time. The shake grows with the square
+// of trauma, so small knocks stay subtle. The camera is only for looks and
+// never touches the simulation.
+type Camera struct {
+	X, Y         float64 // world point at the middle of the view
+	PrevX, PrevY float64
+	Zoom         float64
+
+	trauma float64
+	time   float64 // drives the shake's noise
+
+	geo    ebiten.GeoM // this frame's world-to-view transform
+	moved  bool        // geo isn't the identity
+	layers [numCameraLayers]*ebiten.Image
+}
+
+func NewCamera() *Camera {
+	c := &Camera{Zoom: 1}
+	c.X, c.Y = screenWidth/2, screenHeight/2
+	c.PrevX, c.PrevY = c.X, c.Y
+	return c
+}
+
+// AddTrauma shakes the camera; trauma is capped at 1.
+func (c *Camera) AddTrauma(t float64) {
+	c.trauma = min(1, c.trauma+t)
+}
+
+// Update eases the camera toward (tx, ty) at the given zoom, keeping the
+// view inside the playfield, and fades the shake. It runs once per tick; dt
+// is the tick's length in default ticks.
+func (c *Camera) Update(tx, ty, zoom, dt float64) {
+	c.PrevX, c.PrevY = c.X, c.Y
+	ease := 1 - math.Pow(1-followEase, dt)
+	c.Zoom += (zoom - c.Zoom) * ease
+	if math.Abs(zoom-c.Zoom) < 0.001 {
+		c.Zoom = zoom
+	}
+	halfW, halfH := screenWidth/(2*c.Zoom), screenHeight/(2*c.Zoom)
+	tx = max(halfW, min(screenWidth-halfW, tx))
+	ty = max(halfH, min(screenHeight-halfH, ty))
+	c.X += (tx - c.X) * ease
+	c.Y += (ty - c.Y) * ease
+	if math.Abs(tx-c.X) < 0.01 && math.Abs(ty-c.Y) < 0.01 {
+		c.X, c.Y = tx, ty
+	}
+	c.trauma = max(0, c.trauma-traumaDecay*dt)
+	c.time += dt
+}
+
+// shakeNoise is a smooth wobble between -1 and 1; each seed wobbles
+// differently.
+func shakeNoise(t, seed float64) float64 {
+	return 0.6*math.Sin(t*0.71+seed*12.9) + 0.4*math.Sin(t*1.33+seed*78.2)
+}
+
+// Frame works out the view for the frame being drawn. alpha is how far into
+// the next tick it is; shake scales the shake, 0 for none.
+func (c *Camera) Frame(alpha, shake float64) {
+	x, y := c.PrevX+(c.X-c.PrevX)*alpha, c.PrevY+(c.Y-c.PrevY)*alpha
+	s := c.trauma * c.trauma * shake
+	c.geo.Reset()
+	c.geo.Translate(-x, -y)
+	c.geo.Rotate(maxShakeAngle * s * shakeNoise(c.time, 1))
+	c.geo.Scale(c.Zoom, c.Zoom)
+	c.geo.Translate(screenWidth/2+maxShakeShift*s*shakeNoise(c.time, 2), screenHeight/2+maxShakeShift*s*shakeNoise(c.time, 3))
+	c.moved = s > 0 || c.Zoom != 1 || x != screenWidth/2 || y != screenHeight/2
+}
+
+// ScreenToWorld is the playfield point under screen point (x, y) in the
+// last frame drawn.
+func (c *Camera) ScreenToWorld(x, y float64) (float64, float64) {
+	geo := c.geo
+	if !geo.IsInvertible() {
+		return x, y
+	}
+	geo.Invert()
+	return geo.Apply(x, y)
+}
+
+// Visible is the playfield rectangle the last frame showed on a w x h screen,
+// grown to hold all of it when the view is rotated by the shake.
+func (c *Camera) Visible(w, h float64) (x0, y0, x1, y1 float64) {
+	geo := c.geo
+	if !geo.IsInvertible() {
+		return 0, 0, w, h
+	}
+	geo.Invert()
+	x0, y0, x1, y1 = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, corner := range [4][2]float64{{0, 0}, {w, 0}, {0, h}, {w, h}} {
+		x, y := geo.Apply(corner[0], corner[1])
+		x0, y0, x1, y1 = min(x0, x), min(y0, y), max(x1, x), max(y1, y)
+	}
+	return x0, y0, x1, y1
+}
+
+// View returns the image to draw world content on for dst. When the camera
+// isn't moving anything that's dst itself; otherwise it's one of the camera's
+// layers, which Present then draws on dst.
+func (c *Camera) View(dst *ebiten.Image, layer int) *ebiten.Image {
+	if !c.moved {
+		return dst
+	}
+	fit(&c.layers[layer], screenWidth, screenHeight)
+	return c.layers[layer]
+}
+
+// Present draws a layer from View on dst through the camera.
+func (c *Camera) Present(dst *ebiten.Image, layer int) {
+	if !c.moved {
+		return
+	}
+	op := &ebiten.DrawImageOptions{GeoM: c.geo, Filter: ebiten.FilterLinear}
+	dst.DrawImage(c.layers[layer], op)
+}
+
+// --- Camera Control ---
+
+// shakeCamera adds trauma, unless rollback is replaying what already shook.
+func (g *Game) shakeCamera(trauma float64) {
+	if !g.resimulating {
+		g.camera.AddTrauma(trauma)
+	}
+}
+
+// hitStop freezes the game for a few ticks to give a kill some weight.
+// Online games can't pause one side, so they skip it.
+func (g *Game) hitStop(ticks int) {
+	if g.cameraOpts.HitStop && g.net == nil && !g.resimulating {
+		g.hitStopTicks = max(g.hitStopTicks, g.ticks(ticks))
+	}
+}
+
+// updateCamera follows the players still in the run.
+func (g *Game) updateCamera() {
+	x, y, n := 0.0, 0.0, 0
+	for _, p := range g.players.Items {
+		if !p.Out {
+			cx, cy := p.Center()
+			x, y, n = x+cx, y+cy, n+1
+		}
+	}
+	if n == 0 {
+		x, y = g.camera.X, g.camera.Y
+	} else {
+		x, y = x/float64(n), y/float64(n)
+	}
+	g.camera.Update(x, y, zoomLevels[g.cameraOpts.Zoom], g.tickScale())
+}
+
+// shakeSetting is how strongly the camera shakes, 0 when it's off.
+func (g *Game) shakeSetting() float64 {
+	if !g.cameraOpts.Shake {
+		return 0
+	}
+	return g.cameraOpts.Amount
+}
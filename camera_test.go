@@ -0,0 +1,32 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCameraVisible(t *testing.T) {
+	const w, h = screenWidth, screenHeight
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+	c := NewCamera()
+	c.Frame(0, 0)
+	if x0, y0, x1, y1 := c.Visible(w, h); !near(x0, 0) || !near(y0, 0) || !near(x1, w) || !near(y1, h) {
+		t.Errorf("still camera shows (%v, %v)-(%v, %v), want the whole playfield", x0, y0, x1, y1)
+	}
+
+	c.Zoom = 2
+	c.Frame(0, 0)
+	if x0, y0, x1, y1 := c.Visible(w, h); !near(x0, w/4) || !near(y0, h/4) || !near(x1, w*3/4) || !near(y1, h*3/4) {
+		t.Errorf("2x zoom shows (%v, %v)-(%v, %v), want the middle quarter", x0, y0, x1, y1)
+	}
+
+	// A shaken view pokes past the playfield, which the background must cover
+	c.Zoom = 1
+	c.AddTrauma(1)
+	c.time = 1
+	c.Frame(0, 1)
+	if x0, y0, x1, y1 := c.Visible(w, h); x0 >= 0 && y0 >= 0 && x1 <= w && y1 <= h {
+		t.Errorf("shaken camera shows (%v, %v)-(%v, %v), inside the playfield", x0, y0, x1, y1)
+	}
+}
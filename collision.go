@@ -0,0 +1,196 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+// --- Collision Shapes ---
+
+type Shape int
+
+const (
+	ShapeBox     Shape = iota // axis-aligned rectangle
+	ShapeCircle               // HW is the radius
+	ShapeRotRect              // rectangle rotated by Angle about its center
+)
+
+// Collider is an entity's hit shape, positioned by its center.
+type Collider struct {
+	Shape  Shape
+	X, Y   float64
+	HW, HH float64 // half width and height
+	Angle  float64
+}
+
+// boxCollider is the square most entities use: size x size at (x, y).
+func boxCollider(x, y, size float64) Collider {
+	return Collider{Shape: ShapeBox, X: x + size/2, Y: y + size/2, HW: size / 2, HH: size / 2}
+}
+
+// shapeCollider fits shape to the size x size square at (x, y). Rotated rects
+// are turned to face along (vx, vy), as their sprites are.
+func shapeCollider(shape Shape, x, y, size, vx, vy float64) Collider {
+	c := boxCollider(x, y, size)
+	c.Shape = shape
+	if shape == ShapeRotRect {
+		c.Angle = bulletAngle(vx, vy)
+	}
+	return c
+}
+
+// Bounds is the axis-aligned box around the shape.
+func (c Collider) Bounds() (minX, minY, maxX, maxY float64) {
+	hw, hh := c.HW, c.HH
+	switch c.Shape {
+	case ShapeCircle:
+		hh = hw
+	case ShapeRotRect:
+		sin, cos := math.Abs(math.Sin(c.Angle)), math.Abs(math.Cos(c.Angle))
+		hw, hh = cos*c.HW+sin*c.HH, sin*c.HW+cos*c.HH
+	}
+	return c.X - hw, c.Y - hh, c.X + hw, c.Y + hh
+}
+
+// Overlaps reports whether two shapes intersect. Touching edges don't count.
+func Overlaps(a, b Collider) bool {
+	if a.Shape == ShapeCircle && b.Shape != ShapeCircle {
+		a, b = b, a
+	}
+	switch {
+	case a.Shape == ShapeCircle: // Both circles
+		dx, dy, r := a.X-b.X, a.Y-b.Y, a.HW+b.HW
+		return dx*dx+dy*dy < r*r
+	case b.Shape == ShapeCircle:
+		return rectCircleOverlap(a, b)
+	case a.Shape == ShapeBox && b.Shape == ShapeBox:
+		return math.Abs(a.X-b.X) < a.HW+b.HW && math.Abs(a.Y-b.Y) < a.HH+b.HH
+	}
+	return rectsSATOverlap(a, b)
+}
+
+// rectCircleOverlap finds the point of the rectangle nearest the circle's
+// center, in the rectangle's own frame.
+func rectCircleOverlap(r, c Collider) bool {
+	dx, dy := c.X-r.X, c.Y-r.Y
+	if r.Shape == ShapeRotRect {
+		sin, cos := math.Sincos(-r.Angle)
+		dx, dy = dx*cos-dy*sin, dx*sin+dy*cos
+	}
+	nx := dx - max(-r.HW, min(r.HW, dx))
+	ny := dy - max(-r.HH, min(r.HH, dy))
+	return nx*nx+ny*ny < c.HW*c.HW
+}
+
+// rectsSATOverlap tests two (possibly rotated) rectangles with the separating
+// axis theorem: they're apart if their projections miss on any edge normal.
+func rectsSATOverlap(a, b Collider) bool {
+	for _, angle := range [2]float64{a.Angle, b.Angle} {
+		sin, cos := math.Sincos(angle)
+		for _, axis := range [2][2]float64{{cos, sin}, {-sin, cos}} {
+			dist := math.Abs((b.X-a.X)*axis[0] + (b.Y-a.Y)*axis[1])
+			if dist >= projectedRadius(a, axis)+projectedRadius(b, axis) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// projectedRadius is half the length of c's shadow on a unit axis.
+func projectedRadius(c Collider, axis [2]float64) float64 {
+	sin, cos := math.Sincos(c.Angle)
+	return c.HW*math.Abs(cos*axis[0]+sin*axis[1]) + c.HH*math.Abs(-sin*axis[0]+cos*axis[1])
+}
+
+// --- Entity Shapes ---
+
+// grazeCollider is the zone around p where passing bullets count as grazes.
+func (p *Player) grazeCollider() Collider {
+	return boxCollider(p.X-grazeMargin, p.Y-grazeMargin, p.Size+2*grazeMargin)
+}
+
+// --- Broadphase ---
+
+const gridCellSize = 64
+
+// SpatialGrid buckets entities by the grid cells their bounds cover, so a
+// query only tests what's nearby. It covers the screen; anything off screen
+// lands in the edge cells. It's rebuilt every tick and its zero value is
+// ready to use.
+type SpatialGrid struct {
+	cols, rows int
+	cells      [][]int32
+
+	// Query scratch: stamp[id] == query marks ids already returned
+	stamp []uint32
+	query uint32
+	found []int32
+}
+
+// Build refills the grid with n entities.
+func (sg *SpatialGrid) Build(n int, collider func(i int) Collider) {
+	if sg.cells == nil {
+		sg.cols = (screenWidth + gridCellSize - 1) / gridCellSize
+		sg.rows = (screenHeight + gridCellSize - 1) / gridCellSize
+		sg.cells = make([][]int32, sg.cols*sg.rows)
+	}
+	for i := range sg.cells {
+		sg.cells[i] = sg.cells[i][:0]
+	}
+	if len(sg.stamp) < n {
+		sg.stamp = make([]uint32, n+n/2)
+		sg.query = 0
+	}
+	for i := range n {
+		c0, r0, c1, r1 := sg.cellRange(collider(i))
+		for r := r0; r <= r1; r++ {
+			for c := c0; c <= c1; c++ {
+				cell := &sg.cells[r*sg.cols+c]
+				*cell = append(*cell, int32(i))
+			}
+		}
+	}
+}
+
+// BuildFrom refills the grid with the entities in list, which must all have
+// a Hitbox.
+func (sg *SpatialGrid) BuildFrom(list entityList) {
+	sg.Build(list.Len(), func(i int) Collider { return list.At(i).Parts().Collider() })
+}
+
+func (sg *SpatialGrid) cellRange(c Collider) (c0, r0, c1, r1 int) {
+	minX, minY, maxX, maxY := c.Bounds()
+	clamp := func(v float64, n int) int {
+		return max(0, min(n-1, int(math.Floor(v/gridCellSize))))
+	}
+	return clamp(minX, sg.cols), clamp(minY, sg.rows), clamp(maxX, sg.cols), clamp(maxY, sg.rows)
+}
+
+// Query returns the ids of entities that may overlap c, each once and in
+// ascending order so results don't depend on the grid. The slice is reused
+// by the next query.
+func (sg *SpatialGrid) Query(c Collider) []int32 {
+	sg.found = sg.found[:0]
+	if sg.cells == nil {
+		return sg.found
+	}
+	sg.query++
+	if sg.query == 0 { // Wrapped; old stamps could collide
+		clear(sg.stamp)
+		sg.query = 1
+	}
+	c0, r0, c1, r1 := sg.cellRange(c)
+	for r := r0; r <= r1; r++ {
+		for col := c0; col <= c1; col++ {
+			for _, id := range sg.cells[r*sg.cols+col] {
+				if sg.stamp[id] != sg.query {
+					sg.stamp[id] = sg.query
+					sg.found = append(sg.found, id)
+				}
+			}
+		}
+	}
+	slices.Sort(sg.found)
+	return sg.found
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func circle(x, y, r float64) Collider { return Collider{Shape: ShapeCircle, X: x, Y: y, HW: r, HH: r} }
+
+func box(x, y, hw, hh float64) Collider { return Collider{Shape: ShapeBox, X: x, Y: y, HW: hw, HH: hh} }
+
+func rotRect(x, y, hw, hh, angle float64) Collider {
+	return Collider{Shape: ShapeRotRect, X: x, Y: y, HW: hw, HH: hh, Angle: angle}
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Collider
+		want bool
+	}{
+		{"box box overlap", box(0, 0, 5, 5), box(8, 0, 5, 5), true},
+		{"box box touching", box(0, 0, 5, 5), box(10, 0, 5, 5), false},
+		{"box box apart", box(0, 0, 5, 5), box(0, 20, 5, 5), false},
+		{"box box corners touching", box(0, 0, 5, 5), box(10, 10, 5, 5), false},
+
+		{"circle circle overlap", circle(0, 0, 5), circle(9, 0, 5), true},
+		{"circle circle touching", circle(0, 0, 5), circle(6, 8, 5), false},
+		{"circle circle apart", circle(0, 0, 5), circle(20, 0, 5), false},
+
+		{"box circle overlap", box(0, 0, 5, 5), circle(9, 0, 5), true},
+		{"box circle touching side", box(0, 0, 5, 5), circle(10, 0, 5), false},
+		// Inside the bounding boxes' overlap but past the rounded corner
+		{"box circle missing corner", box(0, 0, 5, 5), circle(9, 9, 5), false},
+		{"box circle inside", box(0, 0, 10, 10), circle(1, 1, 2), true},
+
+		{"rot rect box overlap", rotRect(0, 0, 10, 2, math.Pi/4), box(6, 6, 2, 2), true},
+		// A thin diagonal bar whose bounds cover the box without touching it
+		{"rot rect box in bounds only", rotRect(0, 0, 10, 1, math.Pi/4), box(6, -6, 2, 2), false},
+		{"rot rect box touching", rotRect(0, 0, 5, 5, 0), box(10, 0, 5, 5), false},
+
+		{"rot rect circle overlap", rotRect(0, 0, 10, 1, math.Pi/4), circle(5, 5, 2), true},
+		{"rot rect circle in bounds only", rotRect(0, 0, 10, 1, math.Pi/4), circle(5, -5, 2), false},
+		{"rot rect circle touching end", rotRect(0, 0, 10, 1, 0), circle(13, 0, 3), false},
+
+		{"rot rect rot rect crossing", rotRect(0, 0, 10, 1, math.Pi/4), rotRect(0, 0, 10, 1, -math.Pi/4), true},
+		{"rot rect rot rect parallel", rotRect(0, 0, 10, 1, math.Pi/4), rotRect(4, -4, 10, 1, math.Pi/4), false},
+		{"rot rect rot rect touching", rotRect(0, 0, 5, 5, 0), rotRect(10, 0, 5, 5, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Overlaps(tt.a, tt.b); got != tt.want {
+				t.Errorf("Overlaps(a, b) = %v, want %v", got, tt.want)
+			}
+			if got := Overlaps(tt.b, tt.a); got != tt.want {
+				t.Errorf("Overlaps(b, a) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// broadphaseScene scatters bullets and enemies over the screen.
+func broadphaseScene(bullets, enemies int) (bs, es []Collider) {
+	r := rand.New(rand.NewSource(1))
+	shapes := [3]Shape{ShapeBox, ShapeCircle, ShapeRotRect}
+	place := func(n int, size float64) []Collider {
+		cs := make([]Collider, n)
+		for i := range cs {
+			cs[i] = shapeCollider(shapes[r.Intn(3)], r.Float64()*screenWidth, r.Float64()*screenHeight, size, r.Float64()*2-1, r.Float64()*2-1)
+		}
+		return cs
+	}
+	return place(bullets, 6), place(enemies, 32)
+}
+
+func TestGridMatchesBruteForce(t *testing.T) {
+	bs, es := broadphaseScene(1000, 200)
+	var grid SpatialGrid
+	grid.Build(len(es), func(i int) Collider { return es[i] })
+	for i, b := range bs {
+		var want, got []int32
+		for j, e := range es {
+			if Overlaps(b, e) {
+				want = append(want, int32(j))
+			}
+		}
+		for _, j := range grid.Query(b) {
+			if Overlaps(b, es[j]) {
+				got = append(got, j)
+			}
+		}
+		if len(got) != len(want) {
+			t.Fatalf("bullet %d: grid found %v, brute force %v", i, got, want)
+		}
+		for k := range got {
+			if got[k] != want[k] {
+				t.Fatalf("bullet %d: grid found %v, brute force %v", i, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkBroadphase(b *testing.B) {
+	bs, es := broadphaseScene(1000, 200)
+	b.Run("grid", func(b *testing.B) {
+		var grid SpatialGrid
+		hits := 0
+		b.ReportAllocs()
+		for range b.N {
+			hits = 0
+			grid.Build(len(es), func(i int) Collider { return es[i] })
+			for _, bc := range bs {
+				for _, j := range grid.Query(bc) {
+					if Overlaps(bc, es[j]) {
+						hits++
+					}
+				}
+			}
+		}
+		b.ReportMetric(float64(hits), "hits/op")
+	})
+	b.Run("brute", func(b *testing.B) {
+		hits := 0
+		b.ReportAllocs()
+		for range b.N {
+			hits = 0
+			for _, bc := range bs {
+				for _, ec := range es {
+					if Overlaps(bc, ec) {
+						hits++
+					}
+				}
+			}
+		}
+		b.ReportMetric(float64(hits), "hits/op")
+	})
+}
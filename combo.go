@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// --- Combo and Scoring ---
+
+const (
+	comboWindow    = 120 // ticks to land the next kill before the multiplier decays
+	killsPerLevel  = 5   // combo kills needed to raise the multiplier by one
+	maxMultiplier  = 8
+	grazeMargin    = 12.0 // how close an enemy bullet must pass to count as a graze
+	grazePoints    = 2
+	popupLifetime  = 45
+	popupRiseSpeed = 0.8
+	popupHeight    = 16 // so a popup rises fully off screen before it's dropped
+)
+
+// Popup is a floating score number shown where points were earned. X is the
+// middle of the text.
+type Popup struct {
+	Transform
+	Velocity
+	Life
+	Points int
+	Color  color.RGBA
+}
+
+// Multiplier is the current combo multiplier.
+func (g *Game) Multiplier() int {
+	m := 1 + g.combo/killsPerLevel
+	if m > maxMultiplier {
+		m = maxMultiplier
+	}
+	return m
+}
+
+// addScore awards base points to p, scaled by the combo and p's pickup
+// multiplier, and shows a popup at (x, y). Points also go to the team score.
+func (g *Game) addScore(p *Player, base int, x, y float64, clr color.RGBA) {
+	if base == 0 {
+		return
+	}
+	points := base * g.Multiplier()
+	if p.Effects[PickupMultiplier] > 0 {
+		points *= 2
+	}
+	g.score += points
+	p.Score += points
+	g.popups.Add(Popup{
+		Transform: Transform{X: x, Y: y, Size: popupHeight},
+		Velocity:  Velocity{SpeedY: -popupRiseSpeed},
+		Life:      Life{Max: popupLifetime},
+		Points:    points,
+		Color:     clr,
+	})
+}
+
+// registerKill extends the combo chain.
+func (g *Game) registerKill() {
+	g.combo++
+	g.comboTimer = g.ticks(comboWindow)
+	if g.combo > g.stats.MaxCombo {
+		g.stats.MaxCombo = g.combo
+	}
+}
+
+// breakCombo drops the chain entirely, e.g. when the player is hit.
+func (g *Game) breakCombo() {
+	g.combo = 0
+	g.comboTimer = 0
+}
+
+// updateCombo decays the chain one multiplier level at a time once the
+// window runs out without a kill.
+func (g *Game) updateCombo() {
+	if g.combo == 0 {
+		return
+	}
+	g.comboTimer--
+	if g.comboTimer > 0 {
+		return
+	}
+	g.combo -= killsPerLevel
+	if g.combo <= 0 {
+		g.breakCombo()
+		return
+	}
+	g.comboTimer = g.ticks(comboWindow / 2)
+}
+
+// checkGraze awards points the first time an enemy bullet passes close to the
+// player p without hitting.
+func (g *Game) checkGraze(p *Player, eb *EnemyBullet) {
+	if eb.Grazed || !Overlaps(eb.Parts().Collider(), p.grazeCollider()) {
+		return
+	}
+	eb.Grazed = true
+	g.stats.Grazes++
+	p.Stats.Grazes++
+	if g.combo > 0 {
+		g.comboTimer = g.ticks(comboWindow)
+	}
+	g.addScore(p, g.mode.GrazePoints(grazePoints), eb.X, eb.Y, color.RGBA{0, 255, 255, 255})
+}
+
+func (g *Game) drawPopups(screen *ebiten.Image) {
+	for _, pp := range g.popups.Items {
+		line := fmt.Sprintf("+%d", pp.Points)
+		x, y := pp.Lerp(g.clock.Alpha)
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(x-float64(len(line))*4, y)
+		op.ColorScale.ScaleWithColor(pp.Color)
+		op.ColorScale.ScaleAlpha(1 - float32(pp.Age)/float32(g.ticks(pp.Max)))
+		text.Draw(screen, line, fontFace, op)
+	}
+}
+
+// drawComboHUD shows the multiplier and a bar for the time left in the window.
+func (g *Game) drawComboHUD(screen *ebiten.Image) {
+	if g.combo == 0 {
+		return
+	}
+	line := fmt.Sprintf("x%d  COMBO %d", g.Multiplier(), g.combo)
+	x := float64(screenWidth)/2 - float64(len(line))*4
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, 10)
+	op.ColorScale.ScaleWithColor(color.RGBA{255, 220, 0, 255})
+	text.Draw(screen, line, fontFace, op)
+
+	barW := float32(len(line) * 8)
+	vector.DrawFilledRect(screen, float32(x), 28, barW, 3, color.RGBA{60, 60, 60, 200}, false)
+	vector.DrawFilledRect(screen, float32(x), 28, barW*float32(g.comboTimer)/float32(g.ticks(comboWindow)), 3, color.RGBA{255, 220, 0, 255}, false)
+}
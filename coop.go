@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// --- Local Co-op ---
+
+const (
+	maxPlayers    = 2
+	coopContinues = 2   // continues per player; the shared pool holds both players' worth
+	respawnDelay  = 120 // ticks before a continued player comes back
+)
+
+var playerColors = [maxPlayers]color.RGBA{
+	{255, 0, 0, 255},
+	{0, 220, 90, 255},
+}
+
+// coop reports whether a second player is in the run.
+func (g *Game) coop() bool {
+	return g.players.Len() > 1
+}
+
+// playerAt returns player i, or nil if they haven't joined.
+func (g *Game) playerAt(i int) *Player {
+	if i < 0 || i >= g.players.Len() {
+		return nil
+	}
+	return g.players.Items[i]
+}
+
+// newPlayer creates player i at their spawn point with the difficulty's lives
+// and bombs.
+func (g *Game) newPlayer(i int) Player {
+	p := NewPlayer(float64(screenWidth/2+64*i), float64(screenHeight/2))
+	p.Index = i
+	p.Color = playerColors[i]
+	p.Lives = g.difficulty.StartLives
+	p.Bombs = g.difficulty.StartBombs
+	return p
+}
+
+// joinPlayer brings player 2 into the run. The daily challenge stays single
+// player so its leaderboard is comparable.
+func (g *Game) joinPlayer() {
+	if g.coop() || g.isDaily() {
+		return
+	}
+	g.players.Add(g.newPlayer(g.players.Len()))
+	g.continues = coopContinues * maxPlayers
+	for _, p := range g.players.Items {
+		p.Continues = coopContinues
+	}
+}
+
+// nearestPlayer returns the living player closest to (x, y), or nil if
+// everyone is down.
+func (g *Game) nearestPlayer(x, y float64) *Player {
+	var best *Player
+	bestDist := 0.0
+	for _, p := range g.players.Items {
+		if p.Out {
+			continue
+		}
+		dx, dy := p.X+p.Size/2-x, p.Y+p.Size/2-y
+		if d := dx*dx + dy*dy; best == nil || d < bestDist {
+			best, bestDist = p, d
+		}
+	}
+	return best
+}
+
+// useContinue spends a continue on p from their own stock or the shared pool.
+// Single player runs have no continues.
+func (g *Game) useContinue(p *Player) bool {
+	if !g.coop() {
+		return false
+	}
+	if g.sharedContinues {
+		if g.continues == 0 {
+			return false
+		}
+		g.continues--
+		return true
+	}
+	if p.Continues == 0 {
+		return false
+	}
+	p.Continues--
+	return true
+}
+
+// anyPlayerIn reports whether someone is still alive or waiting to respawn.
+func (g *Game) anyPlayerIn() bool {
+	for _, p := range g.players.Items {
+		if !p.Out || p.Respawn > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// updateRespawns counts down continued players and brings them back.
+func (g *Game) updateRespawns() {
+	for _, p := range g.players.Items {
+		if !p.Out || p.Respawn == 0 {
+			continue
+		}
+		p.Respawn--
+		if p.Respawn == 0 {
+			p.Out = false
+			p.X, p.Y = float64(screenWidth/2+64*p.Index), float64(screenHeight/2)
+			p.Lives = g.difficulty.StartLives
+			p.Invuln = g.ticks(120)
+		}
+	}
+}
+
+// continuesLeft is how many continues p can still use.
+func (g *Game) continuesLeft(p *Player) int {
+	if g.sharedContinues {
+		return g.continues
+	}
+	return p.Continues
+}
+
+// --- Co-op HUD ---
+
+// drawJoinHint tells a second player how to drop in.
+func (g *Game) drawJoinHint(screen *ebiten.Image) {
+	if g.coop() || g.isDaily() || g.spectator != nil {
+		return
+	}
+	line := "P2: press . or Start to join"
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(float64(screenWidth)/2-float64(len(line))*4, float64(screenHeight)-44)
+	op.ColorScale.ScaleWithColor(color.RGBA{160, 160, 160, 255})
+	text.Draw(screen, line, fontFace, op)
+}
+
+// playerStatusLine is the co-op HUD header for p.
+func (g *Game) playerStatusLine(p *Player) string {
+	switch {
+	case p.Out && p.Respawn > 0:
+		return fmt.Sprintf("P%d: %d  BACK IN %ds", p.Index+1, p.Score, int(math.Ceil(g.seconds(p.Respawn))))
+	case p.Out:
+		return fmt.Sprintf("P%d: %d  OUT", p.Index+1, p.Score)
+	}
+	return fmt.Sprintf("P%d: %d  Cont: %d", p.Index+1, p.Score, g.continuesLeft(p))
+}
+
+// deathCardHeight grows the end card to fit a stats line per co-op player.
+func (g *Game) deathCardHeight() float64 {
+	if g.coop() {
+		return 400 + 24*float64(g.players.Len())
+	}
+	return 400
+}
+
+// playerStatsLine summarizes p's run for the co-op end card.
+func playerStatsLine(p *Player) string {
+	return fmt.Sprintf("P%d: %d pts  %d kills  %.0f%% acc  %d hits",
+		p.Index+1, p.Score, p.Stats.Kills, p.Stats.Accuracy(), p.Stats.HitsTaken)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// --- Daily Challenge ---
+
+// dailyFile stores the daily leaderboards, alongside scoreFile.
+var (
+	dailyFile = "daily_scores.json"
+	daily     DailyData
+)
+
+// DailyData maps a UTC date ("2006-01-02") to each profile's score that day.
+// An entry is written when the attempt starts, so quitting doesn't earn a retry.
+type DailyData struct {
+	Days map[string]map[string]int `json:"days"`
+}
+
+func loadDaily() {
+	daily.Days = make(map[string]map[string]int)
+	f, err := os.Open(dailyFile)
+	if err != nil {
+		return // No file yet
+	}
+	defer f.Close()
+	json.NewDecoder(f).Decode(&daily)
+	if daily.Days == nil {
+		daily.Days = make(map[string]map[string]int)
+	}
+}
+
+func saveDaily() {
+	f, err := os.Create(dailyFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	json.NewEncoder(f).Encode(daily)
+}
+
+// dailyDate is today's date in UTC, so everyone shares the same challenge.
+func dailyDate() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+// dailySeed derives the run seed from a date.
+func dailySeed(date string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("2D-GO daily " + date))
+	return int64(h.Sum64())
+}
+
+// hasPlayedDaily reports whether name already used today's scored attempt.
+func hasPlayedDaily(date, name string) bool {
+	_, ok := daily.Days[date][name]
+	return ok
+}
+
+func getTopDailyScores(date string, n int) [][2]string {
+	type pair struct {
+		Name  string
+		Score int
+	}
+	var pairs []pair
+	for name, score := range daily.Days[date] {
+		pairs = append(pairs, pair{name, score})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score == pairs[j].Score {
+			return pairs[i].Name < pairs[j].Name
+		}
+		return pairs[i].Score > pairs[j].Score
+	})
+	top := [][2]string{}
+	for i := 0; i < n && i < len(pairs); i++ {
+		top = append(top, [2]string{pairs[i].Name, fmt.Sprintf("%d", pairs[i].Score)})
+	}
+	return top
+}
+
+// --- Daily Modifiers ---
+
+type DailyModifier struct {
+	Name  string
+	Apply func(d *Difficulty)
+}
+
+var dailyModifiers = []DailyModifier{
+	{"Double-Speed Bullets", func(d *Difficulty) { d.EnemyBulletSpeed *= 2 }},
+	{"Fast Enemies", func(d *Difficulty) { d.EnemySpeedScale *= 1.5 }},
+	{"Swarm", func(d *Difficulty) { d.SpawnStart = d.SpawnStart * 2 / 3; d.SpawnMin = d.SpawnMin * 2 / 3 }},
+	{"Trigger Happy", func(d *Difficulty) { d.FireCooldownMin /= 2; d.FirstShotMin /= 2 }},
+	{"No Bombs", func(d *Difficulty) { d.StartBombs = 0 }},
+	{"Extra Lives", func(d *Difficulty) { d.StartLives += 2 }},
+	{"Lucky Drops", func(d *Difficulty) { d.DropChanceScale *= 2 }},
+	{"Sluggish", func(d *Difficulty) { d.PlayerSpeed *= 0.75 }},
+}
+
+// dailyModifiersFor picks zero to two modifiers from the seed.
+func dailyModifiersFor(seed int64) []DailyModifier {
+	r := rand.New(rand.NewSource(seed))
+	n := r.Intn(3)
+	var out []DailyModifier
+	for _, i := range r.Perm(len(dailyModifiers))[:n] {
+		out = append(out, dailyModifiers[i])
+	}
+	return out
+}
+
+// dailyDifficulty is Normal with the day's modifiers applied.
+func dailyDifficulty(seed int64) *Difficulty {
+	d := *difficulties[defaultDifficulty]
+	d.Name = "Daily"
+	for _, m := range dailyModifiersFor(seed) {
+		m.Apply(&d)
+	}
+	d.sanitize()
+	return &d
+}
+
+// dailyModifierNames is a short description of the day's modifiers.
+func dailyModifierNames(seed int64) string {
+	var names []string
+	for _, m := range dailyModifiersFor(seed) {
+		names = append(names, m.Name)
+	}
+	if len(names) == 0 {
+		return "No modifiers"
+	}
+	return strings.Join(names, ", ")
+}
+
+// startDailyAttempt marks today's attempt for the current profile. It reports
+// whether the run counts for the daily leaderboard.
+func (g *Game) startDailyAttempt() bool {
+	if g.username == "" || hasPlayedDaily(g.dailyDate, g.username) {
+		return false
+	}
+	if daily.Days[g.dailyDate] == nil {
+		daily.Days[g.dailyDate] = make(map[string]int)
+	}
+	daily.Days[g.dailyDate][g.username] = 0
+	saveDaily()
+	return true
+}
+
+// recordDaily saves the score of a scored daily attempt.
+func (g *Game) recordDaily() {
+	if !g.dailyScored {
+		return
+	}
+	daily.Days[g.dailyDate][g.username] = g.score
+	saveDaily()
+}
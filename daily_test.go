@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+// spawnRecord is what a daily player sees arrive: which enemy, where, and
+// when it first fires.
+type spawnRecord struct {
+	Frame, ID int
+	Kind      EnemyKind
+	X         float64
+	Cooldown  int
+}
+
+// dailySpawns plays a daily run for n ticks with the inputs from play and
+// lists every enemy as it spawns.
+func dailySpawns(seed int64, n int, play func(tick int) [maxPlayers]Input) ([]spawnRecord, *Game) {
+	g := &Game{difficulty: dailyDifficulty(seed), mode: dailyMode{}, camera: NewCamera(), seed: seed}
+	g.resetRun()
+	g.players.Items[0].Lives = 1000 // Keep the run going
+	var spawns []spawnRecord
+	for tick := range n {
+		in := play(tick)
+		for _, sys := range simSystems {
+			before := g.nextEnemyID
+			sys.Run(g, in)
+			if sys.Name != "spawning" {
+				continue
+			}
+			for _, e := range g.enemies.Items[g.enemies.Len()-(g.nextEnemyID-before):] {
+				spawns = append(spawns, spawnRecord{g.elapsedFrames, e.ID, e.Kind, e.X, e.Cooldown})
+			}
+		}
+	}
+	return spawns, g
+}
+
+func TestDailySpawnsIgnorePlay(t *testing.T) {
+	seed := dailySeed("2026-01-01")
+	idle, _ := dailySpawns(seed, 3000, func(int) [maxPlayers]Input { return [maxPlayers]Input{} })
+	fighting, g := dailySpawns(seed, 3000, func(tick int) [maxPlayers]Input {
+		var in [maxPlayers]Input
+		in[0].Fire = true
+		in[0].Left = tick/45%2 == 0
+		in[0].Right = !in[0].Left
+		in[0].Bomb = tick%600 == 300
+		return in
+	})
+	if g.stats.Kills == 0 {
+		t.Fatal("the fighting run killed nothing, so it didn't use up any rolls")
+	}
+	if len(idle) != len(fighting) {
+		t.Fatalf("%d spawns idle and %d fighting", len(idle), len(fighting))
+	}
+	for i := range idle {
+		if idle[i] != fighting[i] {
+			t.Fatalf("spawn %d: %+v idle but %+v fighting", i, idle[i], fighting[i])
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+)
+
+// --- Difficulty ---
+
+// Difficulty holds the balance numbers for a run. Frame counts are in ticks.
+type Difficulty struct {
+	Name string `json:"name"`
+
+	PlayerSpeed float64 `json:"player_speed"`
+	StartLives  int     `json:"start_lives"`
+	StartBombs  int     `json:"start_bombs"`
+
+	SpawnStart      int `json:"spawn_start"`       // initial ticks between spawn waves
+	SpawnMin        int `json:"spawn_min"`         // fastest the spawn interval can get
+	SpawnStep       int `json:"spawn_step"`        // interval decrease per ramp step
+	SpawnRampFrames int `json:"spawn_ramp_frames"` // ticks between ramp steps
+
+	EnemySpeedScale   float64 `json:"enemy_speed_scale"`
+	EnemyBulletSpeed  float64 `json:"enemy_bullet_speed"`
+	FirstShotMin      int     `json:"first_shot_min"` // ticks before a new enemy first fires
+	FirstShotRange    int     `json:"first_shot_range"`
+	FireCooldownMin   int     `json:"fire_cooldown_min"` // ticks between an enemy's shots
+	FireCooldownRange int     `json:"fire_cooldown_range"`
+
+	DropChanceScale float64 `json:"drop_chance_scale"`
+}
+
+var difficulties = []*Difficulty{
+	{
+		Name: "Easy", PlayerSpeed: 4.5, StartLives: 3, StartBombs: 3,
+		SpawnStart: 110, SpawnMin: 25, SpawnStep: 5, SpawnRampFrames: 180,
+		EnemySpeedScale: 0.8, EnemyBulletSpeed: 3.5,
+		FirstShotMin: 45, FirstShotRange: 75, FireCooldownMin: 90, FireCooldownRange: 75,
+		DropChanceScale: 1.5,
+	},
+	{
+		Name: "Normal", PlayerSpeed: 4, StartLives: 1, StartBombs: 2,
+		SpawnStart: 90, SpawnMin: 10, SpawnStep: 5, SpawnRampFrames: 120,
+		EnemySpeedScale: 1, EnemyBulletSpeed: 5,
+		FirstShotMin: 30, FirstShotRange: 60, FireCooldownMin: 60, FireCooldownRange: 60,
+		DropChanceScale: 1,
+	},
+	{
+		Name: "Hard", PlayerSpeed: 4, StartLives: 1, StartBombs: 1,
+		SpawnStart: 75, SpawnMin: 8, SpawnStep: 5, SpawnRampFrames: 100,
+		EnemySpeedScale: 1.2, EnemyBulletSpeed: 6,
+		FirstShotMin: 20, FirstShotRange: 45, FireCooldownMin: 45, FireCooldownRange: 45,
+		DropChanceScale: 0.8,
+	},
+	{
+		Name: "Insane", PlayerSpeed: 3.5, StartLives: 1, StartBombs: 0,
+		SpawnStart: 60, SpawnMin: 6, SpawnStep: 6, SpawnRampFrames: 80,
+		EnemySpeedScale: 1.4, EnemyBulletSpeed: 7.5,
+		FirstShotMin: 10, FirstShotRange: 30, FireCooldownMin: 30, FireCooldownRange: 30,
+		DropChanceScale: 0.5,
+	},
+}
+
+const defaultDifficulty = 1 // Normal
+
+// Slowest the player can be set to move; at 0 the ship can't dodge.
+const minPlayerSpeed = 0.5
+
+// Enemy bullets slower than this hang on screen; faster ones can skip past a
+// ship between ticks.
+const (
+	minEnemyBulletSpeed = 0.5
+	maxEnemyBulletSpeed = 20.0
+)
+
+// customDifficultyFile holds the Custom preset. Any field left out keeps the
+// Normal value.
+var customDifficultyFile = "difficulty.json"
+
+// loadCustomDifficulty reads the Custom preset from customDifficultyFile,
+// falling back to Normal's numbers if the file is missing or invalid.
+func loadCustomDifficulty() *Difficulty {
+	d := *difficulties[defaultDifficulty]
+	f, err := os.Open(customDifficultyFile)
+	if err == nil {
+		defer f.Close()
+		json.NewDecoder(f).Decode(&d)
+	}
+	d.sanitize()
+	d.Name = customName(&d)
+	return &d
+}
+
+// customName names a Custom preset after a hash of its numbers, so runs with
+// different files don't share a leaderboard.
+func customName(d *Difficulty) string {
+	c := *d
+	c.Name = ""
+	data, _ := json.Marshal(c)
+	h := fnv.New32a()
+	h.Write(data)
+	return fmt.Sprintf("Custom #%08x", h.Sum32())
+}
+
+// isCustom reports whether d came from the Custom preset.
+func (d *Difficulty) isCustom() bool {
+	return strings.HasPrefix(d.Name, "Custom")
+}
+
+// sanitize keeps hand-edited values inside ranges the game can run with.
+func (d *Difficulty) sanitize() {
+	if d.PlayerSpeed < minPlayerSpeed {
+		d.PlayerSpeed = minPlayerSpeed
+	}
+	if d.SpawnMin < 1 {
+		d.SpawnMin = 1
+	}
+	if d.SpawnStart < d.SpawnMin {
+		d.SpawnStart = d.SpawnMin
+	}
+	if d.SpawnStep < 0 {
+		d.SpawnStep = 0 // Never ramp spawns back down
+	}
+	if d.SpawnRampFrames < 1 {
+		d.SpawnRampFrames = 1
+	}
+	if d.StartLives < 1 {
+		d.StartLives = 1
+	}
+	if d.FirstShotMin < 1 {
+		d.FirstShotMin = 1
+	}
+	if d.FirstShotRange < 1 {
+		d.FirstShotRange = 1
+	}
+	if d.FireCooldownMin < 1 {
+		d.FireCooldownMin = 1
+	}
+	if d.FireCooldownRange < 1 {
+		d.FireCooldownRange = 1
+	}
+	d.EnemyBulletSpeed = max(minEnemyBulletSpeed, min(maxEnemyBulletSpeed, d.EnemyBulletSpeed))
+}
+
+// difficultyNames lists every selectable difficulty, Custom last.
+func difficultyNames() []string {
+	names := make([]string, 0, len(difficulties)+1)
+	for _, d := range difficulties {
+		names = append(names, d.Name)
+	}
+	return append(names, "Custom")
+}
+
+// difficultyByIndex returns the preset at i, where len(difficulties) is Custom.
+func difficultyByIndex(i int) *Difficulty {
+	if i >= 0 && i < len(difficulties) {
+		return difficulties[i]
+	}
+	return loadCustomDifficulty()
+}
+
+// selectedDifficulty is the difficulty picked on the menu. The Custom file is
+// read once when it's picked rather than every frame.
+func (g *Game) selectedDifficulty() *Difficulty {
+	if g.difficultyIdx < len(difficulties) {
+		return difficulties[g.difficultyIdx]
+	}
+	if g.custom == nil {
+		g.custom = loadCustomDifficulty()
+	}
+	return g.custom
+}
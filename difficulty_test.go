@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name  string
+		edit  func(d *Difficulty)
+		check func(d *Difficulty) bool
+	}{
+		{"negative spawn step", func(d *Difficulty) { d.SpawnStep = -5 }, func(d *Difficulty) bool { return d.SpawnStep == 0 }},
+		{"zero spawn step kept", func(d *Difficulty) { d.SpawnStep = 0 }, func(d *Difficulty) bool { return d.SpawnStep == 0 }},
+		{"zero bullet speed", func(d *Difficulty) { d.EnemyBulletSpeed = 0 }, func(d *Difficulty) bool { return d.EnemyBulletSpeed == minEnemyBulletSpeed }},
+		{"negative bullet speed", func(d *Difficulty) { d.EnemyBulletSpeed = -3 }, func(d *Difficulty) bool { return d.EnemyBulletSpeed == minEnemyBulletSpeed }},
+		{"huge bullet speed", func(d *Difficulty) { d.EnemyBulletSpeed = 1e9 }, func(d *Difficulty) bool { return d.EnemyBulletSpeed == maxEnemyBulletSpeed }},
+		{"spawn min", func(d *Difficulty) { d.SpawnMin = -1 }, func(d *Difficulty) bool { return d.SpawnMin == 1 }},
+		{"spawn start under min", func(d *Difficulty) { d.SpawnStart, d.SpawnMin = 5, 20 }, func(d *Difficulty) bool { return d.SpawnStart == 20 }},
+		{"ramp frames", func(d *Difficulty) { d.SpawnRampFrames = 0 }, func(d *Difficulty) bool { return d.SpawnRampFrames == 1 }},
+		{"lives", func(d *Difficulty) { d.StartLives = 0 }, func(d *Difficulty) bool { return d.StartLives == 1 }},
+		{"fire ranges", func(d *Difficulty) { d.FirstShotRange, d.FireCooldownRange = 0, -2 }, func(d *Difficulty) bool { return d.FirstShotRange == 1 && d.FireCooldownRange == 1 }},
+		{"fire minimums", func(d *Difficulty) { d.FirstShotMin, d.FireCooldownMin = -10, 0 }, func(d *Difficulty) bool { return d.FirstShotMin == 1 && d.FireCooldownMin == 1 }},
+		{"zero player speed", func(d *Difficulty) { d.PlayerSpeed = 0 }, func(d *Difficulty) bool { return d.PlayerSpeed == minPlayerSpeed }},
+		{"negative player speed", func(d *Difficulty) { d.PlayerSpeed = -4 }, func(d *Difficulty) bool { return d.PlayerSpeed == minPlayerSpeed }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := *difficulties[defaultDifficulty]
+			tt.edit(&d)
+			d.sanitize()
+			if !tt.check(&d) {
+				t.Errorf("sanitized to %+v", d)
+			}
+		})
+	}
+}
+
+func TestPresetsAreSane(t *testing.T) {
+	for _, p := range difficulties {
+		d := *p
+		d.sanitize()
+		if d != *p {
+			t.Errorf("%s: sanitize changed the preset to %+v", p.Name, d)
+		}
+	}
+}
+
+// loadCustom reads data as the Custom preset.
+func loadCustom(t *testing.T, data string) *Difficulty {
+	t.Helper()
+	saved := customDifficultyFile
+	t.Cleanup(func() { customDifficultyFile = saved })
+	customDifficultyFile = filepath.Join(t.TempDir(), "difficulty.json")
+	if err := os.WriteFile(customDifficultyFile, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return loadCustomDifficulty()
+}
+
+func TestCustomBoardFollowsFile(t *testing.T) {
+	a := loadCustom(t, `{"start_lives": 5}`)
+	if got := loadCustom(t, `{"start_lives": 5}`); got.Name != a.Name {
+		t.Errorf("same file named %q and %q", a.Name, got.Name)
+	}
+	if got := loadCustom(t, `{"start_lives": 6}`); got.Name == a.Name {
+		t.Errorf("different files both named %q", a.Name)
+	}
+	// Numbers that sanitize to the same preset play the same game
+	if x, y := loadCustom(t, `{"spawn_step": -3}`), loadCustom(t, `{"spawn_step": 0}`); x.Name != y.Name {
+		t.Errorf("clamped file named %q, want %q", x.Name, y.Name)
+	}
+
+	g := newTestGame(1)
+	g.difficultyIdx = len(difficulties)
+	loadCustom(t, `{"start_lives": 5}`)
+	if got := g.selectedDifficulty().Name; got != a.Name {
+		t.Errorf("menu shows board %q, want %q", got, a.Name)
+	}
+	g.difficulty = g.selectedDifficulty()
+	if got, want := g.boardName(), boardName(g.mode, a.Name); got != want {
+		t.Errorf("run goes to board %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// --- Adaptive Director ---
+
+const (
+	directorPeriod    = 60   // ticks between director samples at defaultSimRate
+	directorSmoothing = 0.2  // weight of the newest sample in the moving averages
+	directorMaxStep   = 0.05 // most intensity can move per sample
+	directorCalmSecs  = 30.0 // seconds without damage that count as fully comfortable
+	directorKillRate  = 1.5  // kills per second that count as fully comfortable
+)
+
+// Director watches how the player is doing and nudges spawn rate, enemy fire
+// cadence and pickup frequency. It only reads simulation state, so the same
+// inputs always produce the same intensity.
+type Director struct {
+	Intensity float64 // 0 = struggling, 0.5 = neutral, 1 = cruising
+
+	KillRate float64 // moving average of kills per second
+	Accuracy float64 // moving average of kills per shot
+	HitRate  float64 // moving average of hits taken per second
+	Calm     float64 // seconds since the player last took damage
+
+	lastStats RunStats
+}
+
+// NewDirector returns a director at neutral intensity.
+func NewDirector() Director {
+	return Director{Intensity: 0.5, Accuracy: 0.5}
+}
+
+// Update samples the run stats every directorPeriod ticks, scaled to the
+// run's sim rate.
+func (d *Director) Update(stats RunStats) {
+	period := max(1, directorPeriod*stats.TickRate()/defaultSimRate)
+	if stats.Frames == 0 || stats.Frames%period != 0 {
+		return
+	}
+	secs := float64(period) / float64(stats.TickRate())
+	kills := float64(stats.Kills - d.lastStats.Kills)
+	shots := float64(stats.ShotsFired - d.lastStats.ShotsFired)
+	hits := float64(stats.HitsTaken - d.lastStats.HitsTaken)
+	d.lastStats = stats
+
+	d.KillRate += (kills/secs - d.KillRate) * directorSmoothing
+	if shots > 0 {
+		d.Accuracy += (math.Min(kills/shots, 1) - d.Accuracy) * directorSmoothing
+	}
+	d.HitRate += (hits/secs - d.HitRate) * directorSmoothing
+	if hits > 0 {
+		d.Calm = 0
+	} else {
+		d.Calm += secs
+	}
+
+	target := 0.35*math.Min(d.KillRate/directorKillRate, 1) +
+		0.25*d.Accuracy +
+		0.4*math.Min(d.Calm/directorCalmSecs, 1) -
+		2*d.HitRate
+	target = math.Max(0, math.Min(1, target))
+
+	step := math.Max(-directorMaxStep, math.Min(directorMaxStep, target-d.Intensity))
+	d.Intensity += step
+}
+
+// lerpIntensity maps intensity to a value between easy (0) and hard (1),
+// hitting 1.0 at neutral.
+func (d *Director) lerpIntensity(easy, hard float64) float64 {
+	return easy + (hard-easy)*d.Intensity
+}
+
+// SpawnScale multiplies the spawn interval.
+func (d *Director) SpawnScale() float64 { return d.lerpIntensity(1.3, 0.7) }
+
+// FireScale multiplies enemy fire cooldowns.
+func (d *Director) FireScale() float64 { return d.lerpIntensity(1.3, 0.7) }
+
+// DropScale multiplies pickup drop chances.
+func (d *Director) DropScale() float64 { return d.lerpIntensity(1.4, 0.6) }
+
+// --- Director Hooks ---
+
+// directorOn reports whether the director is steering this run. The daily
+// challenge always runs without it so everyone gets the same game.
+func (g *Game) directorOn() bool {
+	return g.adaptive && !g.isDaily()
+}
+
+// spawnIntervalNow is the spawn interval after the director's adjustment.
+func (g *Game) spawnIntervalNow() int {
+	if !g.directorOn() {
+		return g.spawnInterval
+	}
+	return int(math.Round(float64(g.spawnInterval) * g.director.SpawnScale()))
+}
+
+// scaleFireCooldown applies the director's fire cadence to a cooldown.
+func (g *Game) scaleFireCooldown(ticks int) int {
+	if !g.directorOn() {
+		return ticks
+	}
+	return int(math.Round(float64(ticks) * g.director.FireScale()))
+}
+
+// dropChanceScale combines the difficulty and director drop adjustments.
+func (g *Game) dropChanceScale() float64 {
+	scale := g.difficulty.DropChanceScale
+	if g.directorOn() {
+		scale *= g.director.DropScale()
+	}
+	return scale
+}
+
+// --- Debug Overlay ---
+
+func (g *Game) drawDirectorOverlay(screen *ebiten.Image) {
+	if !g.showDirector {
+		return
+	}
+	d := &g.director
+	x, y := float32(10), float32(60)
+	vector.DrawFilledRect(screen, x-4, y-4, 240, 118, color.RGBA{0, 0, 0, 160}, false)
+
+	state := "off"
+	if g.directorOn() {
+		state = "on"
+	}
+	lines := []string{
+		fmt.Sprintf("Director: %s", state),
+		fmt.Sprintf("Intensity: %.2f", d.Intensity),
+		fmt.Sprintf("Kill rate: %.2f/s  Acc: %.0f%%", d.KillRate, d.Accuracy*100),
+		fmt.Sprintf("Hit rate: %.2f/s  Calm: %.0fs", d.HitRate, d.Calm),
+		fmt.Sprintf("Spawn x%.2f Fire x%.2f", d.SpawnScale(), d.FireScale()),
+	}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(x), float64(y)+float64(i)*18)
+		text.Draw(screen, line, fontFace, op)
+	}
+	barY := y + float32(len(lines))*18 + 4
+	vector.DrawFilledRect(screen, x, barY, 200, 6, color.RGBA{60, 60, 60, 255}, false)
+	vector.DrawFilledRect(screen, x, barY, 200*float32(d.Intensity), 6, color.RGBA{255, 80, 80, 255}, false)
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"image/color"
+)
+
+// --- Components ---
+//
+// Every kind of game object is a struct that embeds the components it has,
+// so code that knows the kind can write e.X or e.HP, and has a Parts method
+// handing out pointers to them, so a system can work on anything with the
+// components it needs without knowing the kind. Each kind lives in a Store
+// and g.stores lists them all; the systems, drawing, rollback snapshots and
+// the spectator stream go through that list.
+
+// Transform places an entity: the top-left corner of its square and the
+// square's side.
+type Transform struct {
+	X, Y float64
+	Size float64
+
+	// Position before the last tick, for drawing between ticks
+	PrevX, PrevY float64 `json:"-"`
+	Tracked      bool    `json:"-"`
+}
+
+// Center is the middle of the entity's square.
+func (t *Transform) Center() (float64, float64) {
+	return t.X + t.Size/2, t.Y + t.Size/2
+}
+
+// Velocity moves an entity every tick. Speeds are in pixels per tick at
+// defaultSimRate.
+type Velocity struct {
+	SpeedX, SpeedY float64
+	Bounce         bool `json:",omitempty"` // turns back at the side walls instead of leaving
+}
+
+// Health is damage an entity can take before it dies.
+type Health struct {
+	HP    int
+	Flash int // frames left of the hit flash
+}
+
+const hitFlash = 6 // ticks an entity flashes when hit
+
+// Hurt takes damage and starts a hit flash of flash ticks. It reports whether
+// that was the killing blow.
+func (h *Health) Hurt(damage, flash int) bool {
+	h.HP -= damage
+	h.Flash = flash
+	return h.HP <= 0
+}
+
+// Hitbox gives an entity a collision shape, fitted to its Transform.
+type Hitbox struct {
+	Shape Shape
+}
+
+// Life ages an entity and marks it for removal. Gone entities are taken out
+// of their store by the next Sweep.
+type Life struct {
+	Age  int  // ticks since it appeared
+	Max  int  `json:",omitempty"` // ticks at defaultSimRate it lasts; 0 is until removed
+	Gone bool `json:",omitempty"`
+}
+
+// Draw layers, bottom to top. Players, effects and popups are drawn between
+// them.
+const (
+	layerItems      = iota // pickups, under the players
+	layerShots             // player bullets
+	layerShips             // enemies
+	layerEnemyShots        // enemy bullets, on top so they're never hidden
+)
+
+// Visual is how drawEntities draws an entity.
+type Visual struct {
+	Sprite string     // animation or sprite name; "<sprite>_flash" is shown while Health flashes
+	Tint   color.RGBA // fill for a missing sprite
+	Label  string     `json:",omitempty"` // letter drawn on a missing sprite
+	Layer  int
+	Phase  int  `json:",omitempty"` // ticks added to the animation clock
+	Rotate bool `json:",omitempty"` // turned to face along the velocity
+	Glow   bool `json:",omitempty"` // drawn into the bloom glow too
+	Blink  bool `json:",omitempty"` // blinks through the last two seconds of its Life
+}
+
+// Gun is a player's weapons.
+type Gun struct {
+	Weapon       WeaponType
+	WeaponLevels [numWeaponTypes]int
+	FireCooldown int // ticks until the weapon can fire again
+	Charge       int // ticks the charge button has been held
+}
+
+// Shooter is the AI of an entity that fires at the nearest player.
+type Shooter struct {
+	Cooldown int // ticks until the next shot
+}
+
+// Parts points at an entity's components. A nil field is one it doesn't
+// have; every entity has a Transform.
+type Parts struct {
+	Transform *Transform
+	Velocity  *Velocity
+	Hitbox    *Hitbox
+	Health    *Health
+	Life      *Life
+	Visual    *Visual
+	Shooter   *Shooter
+}
+
+// Entity is anything kept in a Store.
+type Entity interface {
+	Parts() Parts
+}
+
+func (p *Player) Parts() Parts {
+	return Parts{Transform: &p.Transform, Hitbox: &p.Hitbox}
+}
+
+func (b *Bullet) Parts() Parts {
+	return Parts{Transform: &b.Transform, Velocity: &b.Velocity, Hitbox: &b.Hitbox, Life: &b.Life, Visual: &b.Visual}
+}
+
+func (e *Enemy) Parts() Parts {
+	return Parts{Transform: &e.Transform, Velocity: &e.Velocity, Hitbox: &e.Hitbox, Health: &e.Health, Life: &e.Life, Visual: &e.Visual, Shooter: &e.Shooter}
+}
+
+func (eb *EnemyBullet) Parts() Parts {
+	return Parts{Transform: &eb.Transform, Velocity: &eb.Velocity, Hitbox: &eb.Hitbox, Life: &eb.Life, Visual: &eb.Visual}
+}
+
+func (pk *Pickup) Parts() Parts {
+	return Parts{Transform: &pk.Transform, Velocity: &pk.Velocity, Hitbox: &pk.Hitbox, Life: &pk.Life, Visual: &pk.Visual}
+}
+
+func (pp *Popup) Parts() Parts {
+	return Parts{Transform: &pp.Transform, Velocity: &pp.Velocity, Life: &pp.Life}
+}
+
+// gone reports whether the entity has been removed this tick.
+func (p Parts) gone() bool {
+	return p.Life != nil && p.Life.Gone
+}
+
+// Collider is the entity's hit shape. Only entities with a Hitbox have one.
+func (p Parts) Collider() Collider {
+	t := p.Transform
+	var vx, vy float64
+	if p.Velocity != nil {
+		vx, vy = p.Velocity.SpeedX, p.Velocity.SpeedY
+	}
+	return shapeCollider(p.Hitbox.Shape, t.X, t.Y, t.Size, vx, vy)
+}
+
+// leaving reports whether the entity is fully off screen and still heading
+// away, so it will never come back. Enemies spawn below the screen moving up
+// and are kept.
+func (t *Transform) leaving(v *Velocity) bool {
+	return (t.X+t.Size <= 0 && v.SpeedX <= 0) ||
+		(t.X >= screenWidth && v.SpeedX >= 0) ||
+		(t.Y+t.Size <= 0 && v.SpeedY <= 0) ||
+		(t.Y >= screenHeight && v.SpeedY >= 0)
+}
+
+// --- Stores ---
+
+// Store holds every entity of one kind in the order they appeared. Removed
+// entities go back to its pool.
+type Store[T any] struct {
+	Items []*T
+	pool  Pool[T]
+}
+
+// entityList is a run of entities that can be looked at one by one.
+type entityList interface {
+	Len() int
+	At(i int) Entity
+}
+
+// entityStore is a Store of any kind.
+type entityStore interface {
+	entityList
+	Sweep()
+	Clear()
+	save() entityList
+	load(saved entityList)
+	json.Marshaler
+	json.Unmarshaler
+}
+
+// Add puts a copy of v in the store and returns it.
+func (s *Store[T]) Add(v T) *T {
+	e := s.pool.Get()
+	*e = v
+	s.Items = append(s.Items, e)
+	return e
+}
+
+func (s *Store[T]) Len() int { return len(s.Items) }
+
+func (s *Store[T]) At(i int) Entity { return any(s.Items[i]).(Entity) }
+
+// Filter keeps the entities keep accepts, in order, and frees the rest.
+func (s *Store[T]) Filter(keep func(*T) bool) {
+	s.Items = compact(s.Items, &s.pool, keep)
+}
+
+// Sweep frees the entities that are gone.
+func (s *Store[T]) Sweep() {
+	s.Filter(func(e *T) bool { return !any(e).(Entity).Parts().gone() })
+}
+
+// Clear frees every entity.
+func (s *Store[T]) Clear() {
+	s.Items = release(s.Items, &s.pool)
+}
+
+// savedStore is a copy of a Store's entities in a snapshot.
+type savedStore[T any] []T
+
+func (s savedStore[T]) Len() int { return len(s) }
+
+func (s savedStore[T]) At(i int) Entity { return any(&s[i]).(Entity) }
+
+func (s *Store[T]) save() entityList {
+	out := make(savedStore[T], len(s.Items))
+	for i, e := range s.Items {
+		out[i] = *e
+	}
+	return out
+}
+
+func (s *Store[T]) load(saved entityList) {
+	s.Clear()
+	for _, e := range saved.(savedStore[T]) {
+		s.Add(e)
+	}
+}
+
+func (s *Store[T]) MarshalJSON() ([]byte, error) {
+	if s.Items == nil {
+		return []byte("[]"), nil // Same as a store that has been emptied
+	}
+	return json.Marshal(s.Items)
+}
+
+func (s *Store[T]) UnmarshalJSON(data []byte) error {
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	s.load(savedStore[T](items))
+	return nil
+}
+
+// storeNames name the stores in the spectator stream, in g.stores order.
+var storeNames = [...]string{"pickups", "players", "bullets", "enemies", "enemy_bullets", "popups"}
+
+const numStores = len(storeNames)
+
+// stores lists every entity store, in the order entities are drawn within a
+// layer. A new kind of entity needs its store here.
+func (g *Game) stores() [numStores]entityStore {
+	return [numStores]entityStore{&g.pickups, &g.players, &g.bullets, &g.enemies, &g.enemyBullets, &g.popups}
+}
+
+// --- Systems ---
+
+// System is one stage of a simulation tick.
+type System struct {
+	Name string
+	Run  func(g *Game, inputs [maxPlayers]Input)
+}
+
+// simSystems run in this order every tick. Each sees what the earlier ones
+// did this tick, so the order is part of the game's rules: changing it
+// changes how replays and online games play out.
+var simSystems = []System{
+	{"history", (*Game).historySystem},
+	{"clock", (*Game).clockSystem},
+	{"players", (*Game).playerSystem},
+	{"spawning", (*Game).spawnSystem},
+	{"enemy ai", (*Game).enemyAISystem},
+	{"homing", (*Game).homingSystem},
+	{"movement", (*Game).movementSystem},
+	{"aging", (*Game).agingSystem},
+	{"bullet hits", (*Game).bulletHitSystem},
+	{"pickups", (*Game).pickupSystem},
+	{"scoring", (*Game).scoringSystem},
+	{"player hits", (*Game).playerHitSystem},
+	{"rules", (*Game).rulesSystem},
+}
+
+// historySystem remembers where everything was before this tick moves it.
+func (g *Game) historySystem([maxPlayers]Input) {
+	for _, s := range g.stores() {
+		for i := range s.Len() {
+			s.At(i).Parts().Transform.track()
+		}
+	}
+}
+
+// movementSystem moves everything with a Velocity and drops what has left
+// the screen.
+func (g *Game) movementSystem([maxPlayers]Input) {
+	dt := g.tickScale()
+	for _, s := range g.stores() {
+		for i := range s.Len() {
+			e := s.At(i).Parts()
+			t, v := e.Transform, e.Velocity
+			if v == nil {
+				continue
+			}
+			t.X += v.SpeedX * dt
+			t.Y += v.SpeedY * dt
+			if v.Bounce && (t.X < 0 || t.X > float64(screenWidth)-t.Size) {
+				v.SpeedX = -v.SpeedX
+			}
+			if e.Life != nil && t.leaving(v) {
+				e.Life.Gone = true
+			}
+		}
+		s.Sweep()
+	}
+}
+
+// agingSystem ages everything with a Life, drops what has outlived it and
+// fades hit flashes.
+func (g *Game) agingSystem([maxPlayers]Input) {
+	for _, s := range g.stores() {
+		for i := range s.Len() {
+			e := s.At(i).Parts()
+			if h := e.Health; h != nil && h.Flash > 0 {
+				h.Flash--
+			}
+			if l := e.Life; l != nil {
+				l.Age++
+				if l.Max > 0 && l.Age >= g.ticks(l.Max) {
+					l.Gone = true
+				}
+			}
+		}
+		s.Sweep()
+	}
+}
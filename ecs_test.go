@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// runSystems runs the named systems once, in simSystems order.
+func runSystems(g *Game, names ...string) {
+	for _, sys := range simSystems {
+		for _, name := range names {
+			if sys.Name == name {
+				sys.Run(g, [maxPlayers]Input{})
+			}
+		}
+	}
+}
+
+func TestPickupsGoThroughSharedSystems(t *testing.T) {
+	g := newTestGame(1)
+	pk := g.pickups.Add(NewPickup(g.rng, PickupShield, 4, 300))
+	pk.SpeedX = -1
+	runSystems(g, "history", "movement", "aging")
+	if !pk.Tracked || pk.PrevX != 4-pickupSize/2 {
+		t.Errorf("history didn't track the pickup: %+v", pk.Transform)
+	}
+	if pk.SpeedX != 1 {
+		t.Errorf("pickup at the wall has speed %v, want it bounced to 1", pk.SpeedX)
+	}
+	if pk.Age != 1 {
+		t.Errorf("pickup age %d after a tick, want 1", pk.Age)
+	}
+	for range g.ticks(pickupLifetime) - 2 {
+		pk.Y = 300 // Keep it on screen
+		runSystems(g, "movement", "aging")
+	}
+	if g.pickups.Len() != 1 {
+		t.Fatal("pickup expired early")
+	}
+	runSystems(g, "aging")
+	if g.pickups.Len() != 0 {
+		t.Errorf("pickup still there after %d ticks", g.ticks(pickupLifetime))
+	}
+}
+
+func TestPopupsRiseAndExpire(t *testing.T) {
+	g := newTestGame(1)
+	g.addScore(g.players.Items[0], 10, 100, 200, enemyBulletColor)
+	pp := g.popups.Items[0]
+	runSystems(g, "movement", "aging")
+	if pp.Y != 200-popupRiseSpeed {
+		t.Errorf("popup at y %v after a tick, want %v", pp.Y, 200-popupRiseSpeed)
+	}
+	for range g.ticks(popupLifetime) - 1 {
+		runSystems(g, "movement", "aging")
+	}
+	if g.popups.Len() != 0 {
+		t.Errorf("popup still there after %d ticks", g.ticks(popupLifetime))
+	}
+}
+
+// storesJSON encodes every store of g.
+func storesJSON(t *testing.T, g *Game) []byte {
+	t.Helper()
+	var out []byte
+	for _, s := range g.stores() {
+		data, err := s.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, data...)
+	}
+	return out
+}
+
+func TestSnapshotRestoresEveryStore(t *testing.T) {
+	g := newTestGame(1)
+	g.players.Items[0].Lives = 1000
+	in := warmUp(g, 1200)
+	saved := g.saveState()
+	want := storesJSON(t, g)
+	for range 300 {
+		g.step(in)
+	}
+	g.loadState(&saved)
+	if got := storesJSON(t, g); !bytes.Equal(got, want) {
+		t.Errorf("restored entities differ from the snapshot:\n%s\nwant\n%s", got, want)
+	}
+	if got := g.saveState(); got.checksum() != saved.checksum() {
+		t.Error("restored state has a different checksum")
+	}
+}
+
+func TestSpecFrameCarriesEveryStore(t *testing.T) {
+	g := newTestGame(1)
+	g.players.Items[0].Lives = 1000
+	warmUp(g, 1200)
+	f := g.specFrame()
+	data, err := json.Marshal(&f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got SpecFrame
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	watcher := newTestGame(2)
+	watcher.applySpecFrame(&got)
+	for i, s := range g.stores() {
+		w := watcher.stores()[i]
+		if w.Len() != s.Len() {
+			t.Errorf("%s: watcher has %d, streamer %d", storeNames[i], w.Len(), s.Len())
+		}
+	}
+	if a, b := storesJSON(t, watcher), storesJSON(t, g); !bytes.Equal(a, b) {
+		t.Errorf("watcher got\n%s\nstreamer has\n%s", a, b)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image/color"
+	"math/rand"
+)
+
+// --- Enemy Kinds ---
+
+type EnemyKind int
+
+const (
+	EnemyGrunt EnemyKind = iota
+	EnemyScout
+	EnemyHeavy
+	numEnemyKinds
+)
+
+type enemyKindInfo struct {
+	Name   string
+	Size   float64
+	SpeedY float64
+	HP     int
+	Points int
+	Color  color.RGBA
+	Sprite string // animation or sprite name; "<sprite>_flash" is shown when hit
+	Shape  Shape
+}
+
+var enemyKinds = [numEnemyKinds]enemyKindInfo{
+	EnemyGrunt: {Name: "grunt", Size: 32, SpeedY: -2, HP: 1, Points: 10, Color: color.RGBA{0, 0, 255, 255}, Sprite: "grunt"},
+	EnemyScout: {Name: "scout", Size: 24, SpeedY: -3.5, HP: 1, Points: 20, Color: color.RGBA{0, 160, 255, 255}, Sprite: "scout", Shape: ShapeCircle},
+	EnemyHeavy: {Name: "heavy", Size: 44, SpeedY: -1.2, HP: 6, Points: 60, Color: color.RGBA{80, 0, 200, 255}, Sprite: "heavy"},
+}
+
+func (k EnemyKind) String() string {
+	if k < 0 || k >= numEnemyKinds {
+		return "unknown"
+	}
+	return enemyKinds[k].Name
+}
+
+// randomEnemyKind picks a kind for a new spawn. ramp is how far the spawn
+// interval has tightened since the start of the run; scouts and heavies only
+// show up once it has tightened a bit.
+func randomEnemyKind(rng *rand.Rand, ramp int) EnemyKind {
+	r := rng.Intn(100)
+	switch {
+	case ramp >= 30 && r < 10:
+		return EnemyHeavy
+	case ramp >= 10 && r < 35:
+		return EnemyScout
+	default:
+		return EnemyGrunt
+	}
+}
+
+func NewEnemy(rng *rand.Rand, kind EnemyKind, x, y float64, d *Difficulty) Enemy {
+	info := enemyKinds[kind]
+	return Enemy{
+		Transform: Transform{X: x, Y: y, Size: info.Size},
+		Velocity:  Velocity{SpeedY: info.SpeedY * d.EnemySpeedScale},
+		Hitbox:    Hitbox{Shape: info.Shape},
+		Health:    Health{HP: info.HP},
+		Visual:    Visual{Sprite: info.Sprite, Tint: info.Color, Layer: layerShips},
+		Shooter:   Shooter{Cooldown: d.FirstShotMin + rng.Intn(d.FirstShotRange)},
+		Kind:      kind,
+	}
+}
@@ -2,15 +2,21 @@ module 2D-go
 
 go 1.24.3
 
+require (
+	github.com/hajimehoshi/bitmapfont/v3 v3.3.0
+	github.com/hajimehoshi/ebiten v1.12.12
+	github.com/hajimehoshi/ebiten/v2 v2.8.8
+)
+
 require (
 	github.com/ebitengine/gomobile v0.0.0-20240911145611-4856209ac325 // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
+	github.com/ebitengine/oto/v3 v3.3.3 // indirect
 	github.com/ebitengine/purego v0.8.0 // indirect
 	github.com/go-text/typesetting v0.2.0 // indirect
-	github.com/hajimehoshi/bitmapfont/v3 v3.3.0 // indirect
-	github.com/hajimehoshi/ebiten v1.12.12 // indirect
-	github.com/hajimehoshi/ebiten/v2 v2.8.8 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
+	github.com/jfreymuth/oggvorbis v1.0.5 // indirect
+	github.com/jfreymuth/vorbis v1.0.2 // indirect
 	github.com/pierrec/lz4/v4 v4.1.22 // indirect
 	golang.org/x/image v0.27.0 // indirect
 	golang.org/x/sync v0.14.0 // indirect
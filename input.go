@@ -0,0 +1,132 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// --- Input ---
+
+// Input is one tick of player controls. It is sampled once per Update so the
+// simulation never reads the keyboard or mouse directly, which keeps a run
+// reproducible from its recorded inputs.
+type Input struct {
+	Up, Down, Left, Right bool
+	Fire                  bool // fire button held (or auto-fire on)
+	Charge                bool // charge button held
+	SwapWeapon            bool // pressed this tick
+	Bomb                  bool // pressed this tick
+	Join                  bool // pressed this tick; brings player 2 into the run
+	AimX, AimY            float64
+}
+
+// readInputs samples the controls for every local player. Player 1 uses WASD
+// and the mouse. Player 2 uses the arrow keys with , . / ; on the right half of
+// the keyboard. The first gamepad drives player 1 until player 2 joins, then
+// it belongs to player 2.
+func (g *Game) readInputs() [maxPlayers]Input {
+	coop := g.players.Len() > 1
+	pad, hasPad := firstGamepad()
+
+	var in [maxPlayers]Input
+	in[0] = g.readKeyboardMouse(!coop)
+	if hasPad && !coop {
+		readGamepad(&in[0], pad, g.playerAt(0))
+	}
+
+	in[1] = Input{
+		Up:         coop && ebiten.IsKeyPressed(ebiten.KeyArrowUp),
+		Down:       coop && ebiten.IsKeyPressed(ebiten.KeyArrowDown),
+		Left:       coop && ebiten.IsKeyPressed(ebiten.KeyArrowLeft),
+		Right:      coop && ebiten.IsKeyPressed(ebiten.KeyArrowRight),
+		Fire:       ebiten.IsKeyPressed(ebiten.KeyPeriod),
+		Charge:     ebiten.IsKeyPressed(ebiten.KeySemicolon),
+		SwapWeapon: inpututil.IsKeyJustPressed(ebiten.KeyComma),
+		Bomb:       inpututil.IsKeyJustPressed(ebiten.KeySlash),
+		Join:       inpututil.IsKeyJustPressed(ebiten.KeyPeriod),
+	}
+	// Without a stick, player 2 aims straight ahead
+	if p2 := g.playerAt(1); p2 != nil {
+		in[1].AimX, in[1].AimY = p2.X+p2.Size/2, p2.Y+p2.Size/2+stickReach
+	}
+	if hasPad {
+		if coop {
+			readGamepad(&in[1], pad, g.playerAt(1))
+		} else if inpututil.IsStandardGamepadButtonJustPressed(pad, ebiten.StandardGamepadButtonCenterRight) {
+			in[1].Join = true
+		}
+	}
+	return in
+}
+
+// readLocalInput is the control scheme for online play, where each machine
+// drives one ship with the player 1 keys, the mouse and the first gamepad.
+func (g *Game) readLocalInput(p *Player) Input {
+	in := g.readKeyboardMouse(true)
+	if pad, ok := firstGamepad(); ok {
+		readGamepad(&in, pad, p)
+	}
+	return in
+}
+
+// readKeyboardMouse reads WASD (plus the arrow keys when arrows is set), the
+// mouse buttons and the cursor.
+func (g *Game) readKeyboardMouse(arrows bool) Input {
+	cx, cy := ebiten.CursorPosition()
+	aimX, aimY := g.camera.ScreenToWorld(float64(cx), float64(cy))
+	return Input{
+		Up:         ebiten.IsKeyPressed(ebiten.KeyW) || (arrows && ebiten.IsKeyPressed(ebiten.KeyArrowUp)),
+		Down:       ebiten.IsKeyPressed(ebiten.KeyS) || (arrows && ebiten.IsKeyPressed(ebiten.KeyArrowDown)),
+		Left:       ebiten.IsKeyPressed(ebiten.KeyA) || (arrows && ebiten.IsKeyPressed(ebiten.KeyArrowLeft)),
+		Right:      ebiten.IsKeyPressed(ebiten.KeyD) || (arrows && ebiten.IsKeyPressed(ebiten.KeyArrowRight)),
+		Fire:       g.autoFire || ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft),
+		Charge:     ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight),
+		SwapWeapon: inpututil.IsKeyJustPressed(ebiten.KeyQ),
+		Bomb:       inpututil.IsKeyJustPressed(ebiten.KeySpace),
+		AimX:       aimX,
+		AimY:       aimY,
+	}
+}
+
+// firstGamepad returns the first connected gamepad with a standard layout.
+func firstGamepad() (ebiten.GamepadID, bool) {
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// readGamepad adds a gamepad's controls to in: left stick or d-pad to move,
+// right stick to aim, right trigger to fire, left trigger to charge, right
+// bumper for a bomb and left bumper to swap weapons.
+func readGamepad(in *Input, id ebiten.GamepadID, p *Player) {
+	const moveThreshold = 0.5
+	lx := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal)
+	ly := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical)
+	pressed := func(b ebiten.StandardGamepadButton) bool {
+		return ebiten.IsStandardGamepadButtonPressed(id, b)
+	}
+	justPressed := func(b ebiten.StandardGamepadButton) bool {
+		return inpututil.IsStandardGamepadButtonJustPressed(id, b)
+	}
+	in.Up = in.Up || ly < -moveThreshold || pressed(ebiten.StandardGamepadButtonLeftTop)
+	in.Down = in.Down || ly > moveThreshold || pressed(ebiten.StandardGamepadButtonLeftBottom)
+	in.Left = in.Left || lx < -moveThreshold || pressed(ebiten.StandardGamepadButtonLeftLeft)
+	in.Right = in.Right || lx > moveThreshold || pressed(ebiten.StandardGamepadButtonLeftRight)
+	in.Fire = in.Fire || pressed(ebiten.StandardGamepadButtonFrontBottomRight)
+	in.Charge = in.Charge || pressed(ebiten.StandardGamepadButtonFrontBottomLeft)
+	in.Bomb = in.Bomb || justPressed(ebiten.StandardGamepadButtonFrontTopRight)
+	in.SwapWeapon = in.SwapWeapon || justPressed(ebiten.StandardGamepadButtonFrontTopLeft)
+
+	if p == nil {
+		return
+	}
+	sx := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisRightStickHorizontal)
+	sy := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisRightStickVertical)
+	if sx*sx+sy*sy > stickDeadzone*stickDeadzone {
+		in.AimX = p.X + p.Size/2 + sx*stickReach
+		in.AimY = p.Y + p.Size/2 + sy*stickReach
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// --- Online Lobby ---
+
+const (
+	defaultNetAddr  = "127.0.0.1:7777"
+	defaultNetDelay = 2
+	helloInterval   = 30  // ticks between a guest's join attempts
+	joinTimeout     = 600 // ticks a guest waits for the host
+)
+
+// Lobby is the host/join screen for online co-op.
+type Lobby struct {
+	Addr   string
+	Delay  int
+	SimIdx int // index into netSimPresets
+
+	conn    PacketConn // open while hosting or joining
+	hosting bool
+	ticks   int
+}
+
+// openLobby shows the lobby from the menu.
+func (g *Game) openLobby() {
+	g.netStatus = ""
+	g.gameState = "lobby"
+}
+
+// lobbyLayout is shared by updateLobby and drawLobby.
+func lobbyLayout() (cardX, cardY, cardW, cardH float64) {
+	cardW, cardH = 400, 360
+	return float64(screenWidth)/2 - cardW/2, float64(screenHeight)/2 - cardH/2, cardW, cardH
+}
+
+// Port is the port part of the lobby address, used for hosting and for the
+// spectator stream.
+func (l *Lobby) Port() string {
+	if _, p, err := net.SplitHostPort(l.Addr); err == nil {
+		return p
+	}
+	return l.Addr
+}
+
+// wrapConn applies the lobby's simulated connection, if any.
+func (l *Lobby) wrapConn(conn PacketConn) PacketConn {
+	sim := netSimPresets[l.SimIdx]
+	if sim.Loss == 0 && sim.Latency == 0 {
+		return conn
+	}
+	return NewLossyConn(conn, sim.Loss, sim.Latency, sim.Jitter, time.Now().UnixNano())
+}
+
+func (g *Game) cancelLobby(msg string) {
+	if g.lobby.conn != nil {
+		g.lobby.conn.Close()
+		g.lobby.conn = nil
+	}
+	g.netStatus = msg
+}
+
+// hostGame listens on the lobby address's port for a guest.
+func (g *Game) hostGame() {
+	if g.isDaily() {
+		g.netStatus = "The daily challenge is single player"
+		return
+	}
+	port := g.lobby.Port()
+	conn, err := ListenUDP(":" + port)
+	if err != nil {
+		g.netStatus = "Can't host: " + err.Error()
+		return
+	}
+	g.lobby.conn = g.lobby.wrapConn(conn)
+	g.lobby.hosting = true
+	g.lobby.ticks = 0
+	g.netStatus = "Waiting for a player on port " + port
+}
+
+// joinGame starts knocking on the host at the lobby address.
+func (g *Game) joinGame() {
+	conn, err := DialUDP(g.lobby.Addr)
+	if err != nil {
+		g.netStatus = "Can't join: " + err.Error()
+		return
+	}
+	g.lobby.conn = g.lobby.wrapConn(conn)
+	g.lobby.hosting = false
+	g.lobby.ticks = 0
+	g.netStatus = "Joining " + g.lobby.Addr + "..."
+}
+
+// welcomePacket encodes the host's settings for the guest.
+func welcomePacket(ns netSettings) []byte {
+	data, _ := json.Marshal(ns)
+	return append([]byte{packetWelcome}, data...)
+}
+
+// pollLobby runs the handshake: the guest sends hellos until the host answers
+// with a welcome carrying the run's settings, then both start.
+func (g *Game) pollLobby() {
+	l := &g.lobby
+	l.ticks++
+	if !l.hosting {
+		if l.ticks%g.ticks(helloInterval) == 1 {
+			l.conn.Send([]byte{packetHello})
+		}
+		if l.ticks > g.ticks(joinTimeout) {
+			g.cancelLobby("No answer from " + l.Addr)
+			return
+		}
+	}
+	for {
+		data, ok := l.conn.Recv()
+		if !ok {
+			return
+		}
+		switch {
+		case l.hosting && len(data) > 0 && data[0] == packetHello:
+			ns := netSettings{
+				Seed:            time.Now().UnixNano(),
+				Difficulty:      *difficultyByIndex(g.difficultyIdx),
+				Mode:            g.modeIdx,
+				TwinStick:       g.twinStick,
+				Adaptive:        g.adaptive,
+				SharedContinues: g.sharedContinues,
+				Delay:           l.Delay,
+			}
+			welcome := welcomePacket(ns)
+			l.conn.Send(welcome)
+			g.username = g.usernameInput
+			g.startNetplay(l.conn, 0, ns)
+			g.net.welcome = welcome
+			l.conn = nil
+			return
+		case !l.hosting && len(data) > 0 && data[0] == packetWelcome:
+			var ns netSettings
+			if err := json.Unmarshal(data[1:], &ns); err != nil || ns.Mode < 0 || ns.Mode >= len(gameModes) {
+				g.cancelLobby("Host sent bad settings")
+				return
+			}
+			ns.Difficulty.sanitize()
+			if ns.Difficulty.isCustom() {
+				ns.Difficulty.Name = customName(&ns.Difficulty)
+			}
+			g.username = g.usernameInput
+			g.startNetplay(l.conn, 1, ns)
+			l.conn = nil
+			return
+		}
+	}
+}
+
+func (g *Game) updateLobby(ticks int) {
+	l := &g.lobby
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.cancelLobby("")
+		g.gameState = "menu"
+		return
+	}
+	if l.conn != nil {
+		for ; ticks > 0 && l.conn != nil; ticks-- {
+			g.pollLobby()
+		}
+		if clickedIn(g.lobbyButton(3)) {
+			g.cancelLobby("Cancelled")
+		}
+		return
+	}
+
+	// Address field
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if len(l.Addr) < 40 && r < 128 && r != ' ' {
+			l.Addr += string(r)
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(l.Addr) > 0 {
+		l.Addr = l.Addr[:len(l.Addr)-1]
+	}
+
+	_, cardY, _, _ := lobbyLayout()
+	optX := float64(screenWidth)/2 - 100
+	if clickedIn(optX, cardY+132, 200, 32) {
+		l.Delay = (l.Delay + 1) % (maxInputDelay + 1)
+	}
+	if clickedIn(optX, cardY+172, 200, 32) {
+		l.SimIdx = (l.SimIdx + 1) % len(netSimPresets)
+	}
+	switch {
+	case clickedIn(g.lobbyButton(0)):
+		g.hostGame()
+	case clickedIn(g.lobbyButton(1)):
+		g.joinGame()
+	case clickedIn(g.lobbyButton(2)):
+		g.watchGame()
+	case clickedIn(g.lobbyButton(3)):
+		g.gameState = "menu"
+	}
+}
+
+// lobbyButtons labels the bottom row; Cancel replaces Back while connecting.
+var lobbyButtons = []string{"Host", "Join", "Watch", "Back"}
+
+// lobbyButton returns the rectangle of bottom row button i.
+func (g *Game) lobbyButton(i int) (x, y, w, h float64) {
+	_, cardY, _, cardH := lobbyLayout()
+	w, h = 88, 40
+	return float64(screenWidth)/2 - 188 + float64(i)*96, cardY + cardH - h - 24, w, h
+}
+
+func (g *Game) drawLobby(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{0, 0, 0, 255})
+	cardX, cardY, cardW, cardH := lobbyLayout()
+	card := Card{
+		X: cardX, Y: cardY, W: cardW, H: cardH,
+		BgColor: color.RGBA{30, 30, 40, 220},
+		DrawContent: func(screen *ebiten.Image, c *Card) {
+			centerX := c.X + c.W/2
+			drawCentered := func(line string, y float64, clr color.Color) {
+				op := &text.DrawOptions{}
+				op.GeoM.Translate(centerX-float64(len(line))*4, y)
+				op.ColorScale.ScaleWithColor(clr)
+				text.Draw(screen, line, fontFace, op)
+			}
+			drawCentered("Online Co-op", c.Y+36, color.White)
+			drawCentered("Host or join address:", c.Y+68, color.White)
+			addr := g.lobby.Addr
+			if g.lobby.conn == nil {
+				addr += "_"
+			}
+			drawCentered(addr, c.Y+92, color.RGBA{255, 220, 0, 255})
+
+			drawButton(screen, centerX-100, c.Y+132, 200, 32, fmt.Sprintf("Input Delay: %d", g.lobby.Delay))
+			drawButton(screen, centerX-100, c.Y+172, 200, 32, "Net Sim: "+netSimPresets[g.lobby.SimIdx].Label)
+
+			board := boardName(g.mode, g.selectedDifficulty().Name)
+			drawCentered("Host plays: "+board, c.Y+220, color.RGBA{160, 160, 160, 255})
+			drawCentered(g.netStatus, c.Y+248, color.RGBA{255, 180, 120, 255})
+
+			if g.lobby.conn != nil {
+				x, y, w, h := g.lobbyButton(3)
+				drawButton(screen, x, y, w, h, "Cancel")
+				return
+			}
+			for i, label := range lobbyButtons {
+				x, y, w, h := g.lobbyButton(i)
+				drawButton(screen, x, y, w, h, label)
+			}
+		},
+	}
+	card.Draw(screen)
+}
@@ -8,7 +8,6 @@ import (
 	"image/color"
 	_ "image/png"
 	"log"
-	"math"
 	"math/rand"
 	"os"
 	"sort"
@@ -19,10 +18,10 @@ import (
 	"github.com/hajimehoshi/bitmapfont/v3"
 	"github.com/hajimehoshi/ebiten/examples/resources/images"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	rkeyboard "github.com/hajimehoshi/ebiten/v2/examples/resources/images/keyboard"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 // --- Constants and Globals ---
@@ -33,85 +32,195 @@ const (
 )
 
 var (
-	bgImage        *ebiten.Image
-	keyboardImage  *ebiten.Image
-	bulletImg      *ebiten.Image
-	enemyBulletImg *ebiten.Image
-	fontFace       = text.NewGoXFace(bitmapfont.Face)
-	scoreFile      = "scores.json"
-	scores         ScoreData
+	keyboardImage *ebiten.Image
+	fontFace      = text.NewGoXFace(bitmapfont.Face)
+	scoreFile     = "scores.json"
+	scores        ScoreData
 )
 
 // --- Structs and Constructors ---
 
-type viewport struct {
-	x16 int
-	y16 int
+type Player struct {
+	Transform
+	Hitbox
+	Gun
+	Lives   int
+	Invuln  int                 // frames of invulnerability left after a hit
+	Effects [numPickupKinds]int // frames left on each timed pickup effect
+	Bombs   int
+
+	// Co-op
+	Index     int // 0 for player 1, 1 for player 2
+	Color     color.RGBA
+	Score     int
+	Stats     RunStats
+	Continues int  // continues left when they aren't shared
+	Out       bool // out of lives, either waiting to respawn or done
+	Respawn   int  // ticks until a continued player comes back
 }
 
-func (p *viewport) Move() {
-	if bgImage == nil {
-		return
+func NewPlayer(x, y float64) Player {
+	p := Player{Transform: Transform{X: x, Y: y, Size: 32}, Lives: 1, Bombs: startBombs, Color: playerColors[0]}
+	for i := range p.WeaponLevels {
+		p.WeaponLevels[i] = 1
 	}
-	s := bgImage.Bounds().Size()
-	maxY16 := s.Y * 16
-	p.y16 += s.Y / 32
-	p.y16 %= maxY16
+	return p
 }
 
-func (p *viewport) Position() (int, int) {
-	return p.x16, p.y16
-}
+// maxBulletHits is the most enemies one bullet can hit: a full charge shot's
+// pierce plus the hit that spends it.
+const maxBulletHits = 9
 
-type Player struct {
-	X, Y float64
-	Size float64
+type Bullet struct {
+	Transform
+	Velocity
+	Hitbox
+	Life
+	Visual
+	Damage  int
+	Pierce  int // enemies it can pass through before being spent
+	Homing  bool
+	Weapon  WeaponType
+	Charged bool
+	Hits    [maxBulletHits]int // IDs of the enemies hit, so piercing shots hit each once
+	NumHits int
+	Owner   int // index of the player who fired it
 }
 
-func NewPlayer(x, y float64) *Player {
-	return &Player{X: x, Y: y, Size: 32}
+// HasHit reports whether the bullet has already hit the enemy with this ID.
+func (b *Bullet) HasHit(id int) bool {
+	for _, hit := range b.Hits[:b.NumHits] {
+		if hit == id {
+			return true
+		}
+	}
+	return false
 }
 
-type Bullet struct {
-	X, Y   float64
-	SpeedY float64
-	Size   float64
+func (b *Bullet) AddHit(id int) {
+	if b.NumHits < len(b.Hits) {
+		b.Hits[b.NumHits] = id
+		b.NumHits++
+	}
 }
 
 type Enemy struct {
-	X, Y     float64
-	Size     float64
-	SpeedY   float64
-	Cooldown int
-	Dead     bool
+	ID int
+	Transform
+	Velocity
+	Hitbox
+	Health
+	Life
+	Visual
+	Shooter
+	Kind EnemyKind
 }
 
 type EnemyBullet struct {
-	X, Y   float64
-	SpeedX float64
-	SpeedY float64
-	Size   float64
+	Transform
+	Velocity
+	Hitbox
+	Life
+	Visual
+	Grazed bool
 }
 
 type Game struct {
 	keys          []ebiten.Key
-	viewport      viewport
-	player        *Player
-	bullets       []*Bullet
-	enemies       []*Enemy
-	enemyBullets  []*EnemyBullet
+	background    *Background
+	players       Store[Player]
+	bullets       Store[Bullet]
+	enemies       Store[Enemy]
+	enemyBullets  Store[EnemyBullet]
+	pickups       Store[Pickup]
+	nextEnemyID   int
 	spawnCounter  int
 	spawnInterval int
 	elapsedFrames int
-	gameState     string // "menu", "playing", "dead", "settings"
+	gameState     string // "menu", "playing", "dead", "settings", "lobby"
 	score         int
 	deathScore    int // Store score at death
+	stats         RunStats
+	combo         int // kills in the current chain
+	comboTimer    int // ticks left before the chain decays
+	popups        Store[Popup]
+
+	// Bomb shockwave effect
+	bombFrames    int
+	bombX, bombY  float64
+	nextBombScore int
 
 	username      string
 	usernameInput string
 
 	lastGameState string // Track last state for settings
 
+	difficulty    *Difficulty
+	difficultyIdx int         // index into difficultyNames()
+	custom        *Difficulty // Custom preset as last read, for the menu's board
+	adaptive      bool        // let the director adjust pacing during a run (never in the daily)
+	director      Director
+	showDirector  bool // debug overlay, toggled with F3
+
+	// Per-run randomness. Spawns roll on spawnRng and everything else on
+	// rng, so a run's spawns depend only on the seed and not on how it's
+	// played. The states live in the sources so they can be saved and
+	// restored for rollback.
+	seed     int64
+	rng      *rand.Rand
+	rngSrc   *simSource
+	spawnRng *rand.Rand
+	spawnSrc *simSource
+
+	runOver bool // the run has ended; finishRun shows the end card
+
+	mode        GameMode
+	modeIdx     int    // index into gameModes
+	endTitle    string // title of the end-of-run card
+	dailyDate   string // UTC date of the current daily run
+	dailyScored bool   // whether this daily run counts for the leaderboard
+	autoFire    bool
+	twinStick   bool              // aim shots at the cursor/right stick instead of straight down
+	lastInputs  [maxPlayers]Input // most recent inputs, used to draw the crosshairs
+
+	sharedContinues bool // co-op players draw continues from one pool
+	continues       int  // shared continue pool
+
+	// Online co-op
+	net       *Session // active rollback session, nil when playing locally
+	lobby     Lobby
+	netStatus string // last connection message, shown in the lobby
+
+	// Spectator streaming
+	specServer *SpecServer // streams our runs when spectators are enabled
+	spectator  *SpecClient // set while watching someone else's run
+
+	// Particle effects (visual only, outside the simulation)
+	fx            *Particles
+	particleLevel int // index into particleLevels
+	proceduralBg  bool
+	post          *PostFX
+	effects       [numEffects]EffectSetting
+	hurt          int // frames left of the hit effects
+	dragSlider    int // 1 + the settings slider being dragged, or 0
+	camera        *Camera
+	cameraOpts    CameraSettings
+	hitStopTicks  int // ticks left of a hit-stop freeze
+	audio         *Audio
+	thrusters     [maxPlayers]ContinuousEmitter
+	resimulating  bool // rollback is replaying frames; don't spawn effects again
+
+	// Fixed timestep
+	clock      Clock
+	simRateIdx int               // index into simRates
+	sampled    [maxPlayers]Input // controls as of this frame
+	pressed    [maxPlayers]Input // presses not yet used by a tick
+
+	// Collision broadphase, rebuilt every tick
+	enemyGrid       SpatialGrid
+	enemyBulletGrid SpatialGrid
+	pickupGrid      SpatialGrid
+
 	// Settings dropdown state
 	dropdownOpen   bool
 	selectedScreen int
@@ -122,14 +231,30 @@ type Game struct {
 }
 
 type ScoreData struct {
-	HighScores map[string]int `json:"high_scores"`
+	HighScores map[string]int            `json:"high_scores"`      // Normal difficulty board
+	Boards     map[string]map[string]int `json:"boards,omitempty"` // every other board, by name
+	History    []RunRecord               `json:"history,omitempty"`
+}
+
+// RunRecord is one finished run in the score history.
+type RunRecord struct {
+	Name       string      `json:"name"`
+	Score      int         `json:"score"`
+	Seed       int64       `json:"seed"`
+	Mode       string      `json:"mode"`
+	Difficulty string      `json:"difficulty"`
+	Params     *Difficulty `json:"params,omitempty"` // the numbers played, for Custom runs
+	Adaptive   bool        `json:"adaptive,omitempty"`
+	Players    int         `json:"players,omitempty"` // 2 for a co-op run
+	Time       time.Time   `json:"time"`
+	Stats      RunStats    `json:"stats"`
 }
 
+const maxHistory = 500
+
 // --- Asset Initialization ---
 
 func init() {
-	rand.Seed(time.Now().UnixNano())
-
 	// Load keyboard image
 	img, _, err := image.Decode(bytes.NewReader(rkeyboard.Keyboard_png))
 	if err != nil {
@@ -142,29 +267,46 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	bgImage = ebiten.NewImageFromImage(imgBG)
-
-	bulletImg = ebiten.NewImage(6, 6)
-	bulletImg.Fill(color.RGBA{255, 255, 0, 255})
-
-	enemyBulletImg = ebiten.NewImage(6, 6)
-	enemyBulletImg.Fill(color.RGBA{0, 255, 255, 255})
+	bgImages["tile"] = ebiten.NewImageFromImage(imgBG)
 }
 
 // --- Utility Functions ---
 
-func rectsOverlap(x1, y1, s1, x2, y2, s2 float64) bool {
-	return x1 < x2+s2 && x2 < x1+s1 && y1 < y2+s2 && y2 < y1+s1
-}
-
 func loadScores() {
 	scores.HighScores = make(map[string]int)
+	scores.Boards = make(map[string]map[string]int)
 	f, err := os.Open(scoreFile)
 	if err != nil {
 		return // No file yet
 	}
 	defer f.Close()
 	json.NewDecoder(f).Decode(&scores)
+	if scores.HighScores == nil {
+		scores.HighScores = make(map[string]int)
+	}
+	if scores.Boards == nil {
+		scores.Boards = make(map[string]map[string]int)
+	}
+}
+
+// boardScores returns the name -> best score map for a leaderboard, which is
+// nil if nobody has played it yet. The Normal board lives in high_scores so
+// older score files keep working.
+func boardScores(board string) map[string]int {
+	if board == difficulties[defaultDifficulty].Name {
+		return scores.HighScores
+	}
+	return scores.Boards[board]
+}
+
+// submitScore records score on a leaderboard if it beats name's best.
+func submitScore(board, name string, score int) {
+	if board != difficulties[defaultDifficulty].Name && scores.Boards[board] == nil {
+		scores.Boards[board] = make(map[string]int)
+	}
+	if b := boardScores(board); score > b[name] {
+		b[name] = score
+	}
 }
 
 func saveScores() {
@@ -176,13 +318,13 @@ func saveScores() {
 	json.NewEncoder(f).Encode(scores)
 }
 
-func getTopScores(n int) [][2]string {
+func getTopScores(board string, n int) [][2]string {
 	type pair struct {
 		Name  string
 		Score int
 	}
 	var pairs []pair
-	for name, score := range scores.HighScores {
+	for name, score := range boardScores(board) {
 		pairs = append(pairs, pair{name, score})
 	}
 	sort.Slice(pairs, func(i, j int) bool {
@@ -202,11 +344,14 @@ func getTopScores(n int) [][2]string {
 
 func (g *Game) Update() error {
 	g.keys = inpututil.AppendPressedKeys(g.keys[:0])
+	g.clock.Rate = g.simRate()
+	ticks := g.clock.Advance()
+	g.updateAudio()
 
 	// --- Settings Page Logic ---
 	if g.gameState == "settings" {
 		centerX := float64(screenWidth) / 2
-		cardH := 300.0
+		cardH := 460.0
 		cardY := float64(screenHeight)/2 - cardH/2
 		btnW, btnH := 120.0, 40.0
 		btnX := centerX - btnW/2
@@ -257,12 +402,19 @@ func (g *Game) Update() error {
 			return nil
 		}
 
+		// Aim mode toggle; the rest are in settingsToggles
+		aimY := cardY + 160.0
+
 		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
 			x, y := ebiten.CursorPosition()
 			xf, yf := float64(x), float64(y)
 			// Dropdown click
 			if xf >= ddX && xf <= ddX+ddW && yf >= ddY && yf <= ddY+ddH {
 				g.dropdownOpen = !g.dropdownOpen
+			} else if !g.dropdownOpen && xf >= ddX && xf <= ddX+ddW && yf >= aimY && yf <= aimY+ddH {
+				g.twinStick = !g.twinStick
+			} else if i := settingsToggleAt(cardY, xf, yf); !g.dropdownOpen && i >= 0 {
+				settingsToggles[i].Click(g)
 			} else if g.dropdownOpen {
 				// Check if clicked on an option
 				for i := range screenSizes {
@@ -292,6 +444,19 @@ func (g *Game) Update() error {
 		return nil
 	}
 
+	if page, ok := settingsPages[g.gameState]; ok {
+		g.updateSettingsPage(page)
+		return nil
+	}
+	if g.gameState == "lobby" {
+		g.updateLobby(ticks)
+		return nil
+	}
+	if g.gameState == "spectate" {
+		g.updateSpectate(ticks)
+		return nil
+	}
+
 	// --- Start Menu Logic ---
 	if g.gameState == "menu" {
 		// Handle username input
@@ -306,17 +471,28 @@ func (g *Game) Update() error {
 		// Enter to confirm username and start
 		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) && len(g.usernameInput) > 0 {
 			g.username = g.usernameInput
-			g.Reset()
-			g.gameState = "playing"
+			g.Start()
 		}
 
-		// --- Settings Button Click Logic ---
+		// --- Settings, Difficulty and Daily Button Click Logic ---
 		centerX := float64(screenWidth) / 2
 		cardH := 420.0
 		cardY := float64(screenHeight)/2 - cardH/2
+		if clickedIn(centerX+100, cardY+12, 88, 28) {
+			g.openLobby()
+			return nil
+		}
 		btnW, btnH := 120.0, 40.0
-		btnX := centerX - btnW/2
+		btnX := centerX - 188
 		btnY := cardY + cardH - btnH - 24
+		if clickedIn(centerX-60, btnY, btnW, btnH) || inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+			g.difficultyIdx = (g.difficultyIdx + 1) % len(difficultyNames())
+			g.custom = nil // Re-read the file when Custom comes round
+		}
+		if clickedIn(centerX+68, btnY, btnW, btnH) {
+			g.modeIdx = (g.modeIdx + 1) % len(gameModes)
+			g.mode = gameModes[g.modeIdx]
+		}
 		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
 			x, y := ebiten.CursorPosition()
 			xf, yf := float64(x), float64(y)
@@ -332,7 +508,7 @@ func (g *Game) Update() error {
 	// --- Death Screen Logic ---
 	if g.gameState == "dead" {
 		centerX := float64(screenWidth) / 2
-		cardH := 300.0
+		cardH := g.deathCardHeight()
 		cardY := float64(screenHeight)/2 - cardH/2
 		btnW, btnH := 120.0, 40.0
 		btnX := centerX - btnW/2
@@ -352,8 +528,7 @@ func (g *Game) Update() error {
 			}
 			// Play Again button
 			if xf >= btnX && xf <= btnX+btnW && yf >= playAgainBtnY && yf <= playAgainBtnY+btnH {
-				g.Reset()
-				g.gameState = "playing"
+				g.Start()
 			}
 			// Settings button
 			if xf >= btnX && xf <= btnX+btnW && yf >= settingsBtnY && yf <= settingsBtnY+btnH {
@@ -362,8 +537,7 @@ func (g *Game) Update() error {
 			}
 		}
 		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
-			g.Reset()
-			g.gameState = "playing"
+			g.Start()
 		}
 		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
 			g.Reset()
@@ -372,195 +546,83 @@ func (g *Game) Update() error {
 		return nil
 	}
 
-	g.viewport.Move()
-	g.elapsedFrames++ // Track time
-
-	// Player movement
-	const speed = 4.0
-	if g.player != nil {
-		if ebiten.IsKeyPressed(ebiten.KeyW) || ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
-			g.player.Y -= speed
-		}
-		if ebiten.IsKeyPressed(ebiten.KeyS) || ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
-			g.player.Y += speed
-		}
-		if ebiten.IsKeyPressed(ebiten.KeyA) || ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
-			g.player.X -= speed
-		}
-		if ebiten.IsKeyPressed(ebiten.KeyD) || ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
-			g.player.X += speed
-		}
-		// Clamp to screen
-		if g.player.X < 0 {
-			g.player.X = 0
-		}
-		if g.player.Y < 0 {
-			g.player.Y = 0
-		}
-		if g.player.X > float64(screenWidth)-g.player.Size {
-			g.player.X = float64(screenWidth) - g.player.Size
-		}
-		if g.player.Y > float64(screenHeight)-g.player.Size {
-			g.player.Y = float64(screenHeight) - g.player.Size
-		}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF) {
+		g.autoFire = !g.autoFire
 	}
-
-	// Shooting
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && g.player != nil {
-		bullet := &Bullet{
-			X:      g.player.X + g.player.Size/2 - 3,
-			Y:      g.player.Y + g.player.Size,
-			SpeedY: 8,
-			Size:   6,
-		}
-		g.bullets = append(g.bullets, bullet)
+	if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+		g.showDirector = !g.showDirector
 	}
-
-	// Gradually decrease spawnInterval, but not below a minimum (e.g., 10)
-	if g.elapsedFrames%120 == 0 && g.spawnInterval > 10 {
-		g.spawnInterval -= 5
-		if g.spawnInterval < 10 {
-			g.spawnInterval = 10
-		}
+	// Checked once per frame, not per tick: a frame can run no ticks
+	if g.net != nil && inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.endNetplay("You left the game")
+		return nil
 	}
-
-	// Enemy spawning
-	g.spawnCounter++
-	if g.spawnCounter >= g.spawnInterval {
-		g.spawnCounter = 0
-		numEnemies := 1 + (90-g.spawnInterval)/20
-		for i := 0; i < numEnemies; i++ {
-			enemy := &Enemy{
-				X:        float64(32 + rand.Intn(screenWidth-64)),
-				Y:        float64(screenHeight),
-				Size:     32,
-				SpeedY:   -2,
-				Cooldown: 30 + rand.Intn(60),
-			}
-			g.enemies = append(g.enemies, enemy)
+	g.sampleInputs()
+	for ; ticks > 0 && g.gameState == "playing"; ticks-- {
+		g.updateCamera()
+		if g.hitStopTicks > 0 {
+			g.hitStopTicks--
+			continue
 		}
+		g.tick()
+	}
+	if g.hitStopTicks > 0 {
+		g.clock.Alpha = 1 // Hold still rather than drift toward the next tick
 	}
+	return nil
+}
 
-	// Enemy movement and shooting
-	var movedEnemies []*Enemy
-	for _, e := range g.enemies {
-		e.Y += e.SpeedY
-		if e.Y+e.Size < 0 {
-			continue
-		}
-		if g.player != nil {
-			e.Cooldown--
-			if e.Cooldown <= 0 {
-				dx := (g.player.X + g.player.Size/2) - (e.X + e.Size/2)
-				dy := (g.player.Y + g.player.Size/2) - (e.Y + e.Size/2)
-				dist := dx*dx + dy*dy
-				if dist > 0 {
-					length := math.Sqrt(dist)
-					speed := 5.0
-					eb := &EnemyBullet{
-						X:      e.X + e.Size/2 - 3,
-						Y:      e.Y + e.Size/2 - 3,
-						SpeedX: dx / length * speed,
-						SpeedY: dy / length * speed,
-						Size:   6,
-					}
-					g.enemyBullets = append(g.enemyBullets, eb)
-					e.Cooldown = 60 + rand.Intn(60)
-				}
-			}
-		}
-		movedEnemies = append(movedEnemies, e)
+// tick runs one fixed step of a local or online run.
+func (g *Game) tick() {
+	g.updateBackground()
+	if g.net != nil {
+		g.updateNetplay()
+		g.updateEffects()
+		g.broadcastSpectators()
+		return
 	}
-	g.enemies = movedEnemies
-
-	// Enemy bullets movement
-	var activeEnemyBullets []*EnemyBullet
-	for _, eb := range g.enemyBullets {
-		eb.X += eb.SpeedX
-		eb.Y += eb.SpeedY
-		if eb.X+eb.Size > 0 && eb.X < float64(screenWidth) && eb.Y+eb.Size > 0 && eb.Y < float64(screenHeight) {
-			activeEnemyBullets = append(activeEnemyBullets, eb)
-		}
+	inputs := g.takeInputs()
+	g.lastInputs = inputs
+	g.step(inputs)
+	g.updateEffects()
+	g.broadcastSpectators()
+	if g.runOver {
+		g.finishRun()
 	}
-	g.enemyBullets = activeEnemyBullets
-
-	// Player bullets movement
-	var movedBullets []*Bullet
-	for _, b := range g.bullets {
-		b.Y += b.SpeedY
-		if b.Y+b.Size > 0 {
-			movedBullets = append(movedBullets, b)
-		}
+}
+
+// recordRun saves the finished run to the history and, if it's a new record,
+// to the difficulty's leaderboard.
+func (g *Game) recordRun() {
+	if g.username == "" {
+		return
 	}
-	g.bullets = movedBullets
-
-	// Bullet vs Enemy collision
-	var remainingBullets []*Bullet
-	for _, b := range g.bullets {
-		hit := false
-		for _, e := range g.enemies {
-			if !e.Dead && rectsOverlap(b.X, b.Y, b.Size, e.X, e.Y, e.Size) {
-				e.Dead = true
-				hit = true
-				g.score++
-				break
-			}
-		}
-		if !hit {
-			remainingBullets = append(remainingBullets, b)
-		}
+	if g.isDaily() {
+		g.recordDaily()
+	} else if g.clock.Rate == defaultSimRate {
+		// Runs at other sim rates are practice and stay off the leaderboards
+		submitScore(g.boardName(), g.username, g.score)
 	}
-	// Remove dead enemies
-	var survivedEnemies []*Enemy
-	for _, e := range g.enemies {
-		if !e.Dead {
-			survivedEnemies = append(survivedEnemies, e)
-		}
+	run := RunRecord{
+		Name:       g.username,
+		Score:      g.score,
+		Seed:       g.seed,
+		Mode:       g.mode.Name(),
+		Difficulty: g.difficulty.Name,
+		Adaptive:   g.directorOn(),
+		Players:    g.players.Len(),
+		Time:       time.Now().UTC(),
+		Stats:      g.stats,
 	}
-	g.enemies = survivedEnemies
-	g.bullets = remainingBullets
-
-	// Enemy bullet vs Player collision
-	if g.player != nil {
-		var activeEnemyBullets []*EnemyBullet
-		playerHit := false
-		for _, eb := range g.enemyBullets {
-			if rectsOverlap(eb.X, eb.Y, eb.Size, g.player.X, g.player.Y, g.player.Size) {
-				playerHit = true
-				continue
-			}
-			activeEnemyBullets = append(activeEnemyBullets, eb)
-		}
-		g.enemyBullets = activeEnemyBullets
-		if playerHit {
-			g.deathScore = g.score
-			// --- Save high score if it's a new record ---
-			if g.username != "" && g.score > scores.HighScores[g.username] {
-				scores.HighScores[g.username] = g.score
-				saveScores()
-			}
-			g.gameState = "dead"
-			return nil
-		}
+	if g.difficulty.isCustom() {
+		params := *g.difficulty
+		run.Params = &params
 	}
-
-	// Player vs Enemy collision
-	if g.player != nil {
-		for _, e := range g.enemies {
-			if rectsOverlap(g.player.X, g.player.Y, g.player.Size, e.X, e.Y, e.Size) {
-				g.deathScore = g.score
-				// --- Save high score if it's a new record ---
-				if g.username != "" && g.score > scores.HighScores[g.username] {
-					scores.HighScores[g.username] = g.score
-					saveScores()
-				}
-				g.gameState = "dead"
-				break
-			}
-		}
+	scores.History = append(scores.History, run)
+	if len(scores.History) > maxHistory {
+		scores.History = scores.History[len(scores.History)-maxHistory:]
 	}
-
-	return nil
+	saveScores()
 }
 
 // UI
@@ -589,11 +651,20 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				if len(g.usernameInput) == 0 {
 					startMsg = "Type your username to Start"
 				}
+				diffName := difficultyNames()[g.difficultyIdx]
+				board := boardName(g.mode, g.selectedDifficulty().Name)
 				highScoreMsg := ""
 				if g.usernameInput != "" {
-					highScore := scores.HighScores[g.usernameInput]
+					highScore := boardScores(board)[g.usernameInput]
 					highScoreMsg = fmt.Sprintf("High Score: %d", highScore)
 				}
+				today := dailyDate()
+				if g.isDaily() {
+					highScoreMsg = "Today: " + dailyModifierNames(dailySeed(today))
+					if hasPlayedDaily(today, g.usernameInput) {
+						startMsg = "Press ENTER to Practice"
+					}
+				}
 
 				// --- Calculate max width for the top block ---
 				topLines := []string{title, instr, userInput, startMsg}
@@ -641,8 +712,12 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				}
 
 				// --- Leaderboard title and entries ---
-				leaderboardTitle := "Leaderboard (Top 10)"
-				topScores := getTopScores(10)
+				leaderboardTitle := fmt.Sprintf("%s (Top 10)", board)
+				topScores := getTopScores(board, 10)
+				if g.isDaily() {
+					leaderboardTitle = fmt.Sprintf("Daily %s (Top 10)", today)
+					topScores = getTopDailyScores(today, 10)
+				}
 				maxLineWidth := len(leaderboardTitle)
 				lines := make([]string, len(topScores))
 				for i, entry := range topScores {
@@ -669,32 +744,33 @@ func (g *Game) Draw(screen *ebiten.Image) {
 					text.Draw(screen, line, fontFace, textOpEntry)
 				}
 
-				// --- Settings Button (centered) ---
+				// --- Settings, Difficulty and Daily Buttons (side by side) ---
 				btnW, btnH := 120.0, 40.0
-				btnX := centerX - btnW/2
 				btnY := c.Y + c.H - btnH - 24
-				btnImg := ebiten.NewImage(int(btnW), int(btnH))
-				btnImg.Fill(color.RGBA{60, 60, 120, 200})
-				btnOp := &ebiten.DrawImageOptions{}
-				btnOp.GeoM.Translate(btnX, btnY)
-				screen.DrawImage(btnImg, btnOp)
-
-				btnText := "Settings"
-				btnTextWidth := float64(len(btnText)) * 8
-				btnTextOp := &text.DrawOptions{}
-				btnTextOp.GeoM.Translate(centerX-btnTextWidth/2, btnY+10)
-				text.Draw(screen, btnText, fontFace, btnTextOp)
+				drawButton(screen, centerX-188, btnY, btnW, btnH, "Settings")
+				drawButton(screen, centerX-60, btnY, btnW, btnH, "Diff: "+diffName)
+				drawButton(screen, centerX+68, btnY, btnW, btnH, g.mode.Label())
+				drawButton(screen, centerX+100, c.Y+12, 88, 28, "Online")
 			},
 		}
 		card.Draw(screen)
 		return
 	}
 
+	if page, ok := settingsPages[g.gameState]; ok {
+		g.drawSettingsPage(screen, page)
+		return
+	}
+	if g.gameState == "lobby" {
+		g.drawLobby(screen)
+		return
+	}
+
 	if g.gameState == "settings" {
 		screen.Fill(color.RGBA{0, 0, 0, 255})
 
 		centerX := float64(screenWidth) / 2
-		cardW, cardH := 400.0, 300.0
+		cardW, cardH := 400.0, 460.0
 		cardX := centerX - cardW/2
 		cardY := float64(screenHeight)/2 - cardH/2
 
@@ -715,11 +791,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				screenSizes := []string{"640 x 480", "800 x 600", "1024 x 768", "Custom..."}
 
 				// Draw dropdown box (centered)
-				ddImg := ebiten.NewImage(int(ddW), int(ddH))
-				ddImg.Fill(color.RGBA{60, 60, 120, 200})
-				ddOp := &ebiten.DrawImageOptions{}
-				ddOp.GeoM.Translate(ddX, ddY)
-				screen.DrawImage(ddImg, ddOp)
+				fillRect(screen, ddX, ddY, ddW, ddH, buttonColor)
 
 				// Draw selected option (centered in dropdown)
 				selText := screenSizes[g.selectedScreen]
@@ -737,14 +809,28 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				arrowOp.GeoM.Translate(ddX+ddW-24, ddY+8)
 				text.Draw(screen, arrow, fontFace, arrowOp)
 
+				// --- Aim mode toggle (centered, under the dropdown) ---
+				aimY := c.Y + 160.0
+				fillRect(screen, ddX, aimY, ddW, ddH, buttonColor)
+
+				aimText := "Aim: Classic"
+				if g.twinStick {
+					aimText = "Aim: Twin-Stick"
+				}
+				aimTextWidth := float64(len(aimText)) * 8
+				aimTextOp := &text.DrawOptions{}
+				aimTextOp.GeoM.Translate(centerX-aimTextWidth/2, aimY+8)
+				text.Draw(screen, aimText, fontFace, aimTextOp)
+
+				for i, t := range settingsToggles {
+					x, y, w, h := settingsToggleRect(c.Y, i)
+					drawButton(screen, x, y, w, h, t.Label(g))
+				}
+
 				// Draw options if open (centered)
 				if g.dropdownOpen {
 					for i, opt := range screenSizes {
-						optImg := ebiten.NewImage(int(ddW), int(ddH))
-						optImg.Fill(color.RGBA{60, 60, 120, 200})
-						optOp := &ebiten.DrawImageOptions{}
-						optOp.GeoM.Translate(ddX, ddY+ddH+float64(i)*ddH)
-						screen.DrawImage(optImg, optOp)
+						fillRect(screen, ddX, ddY+ddH+float64(i)*ddH, ddW, ddH, buttonColor)
 
 						optWidth := float64(len(opt)) * 8
 						optTextOp := &text.DrawOptions{}
@@ -758,11 +844,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 					dialogW, dialogH := 260.0, 80.0
 					dialogX := centerX - dialogW/2
 					dialogY := c.Y + 160
-					dialogImg := ebiten.NewImage(int(dialogW), int(dialogH))
-					dialogImg.Fill(color.RGBA{30, 30, 40, 240})
-					dialogOp := &ebiten.DrawImageOptions{}
-					dialogOp.GeoM.Translate(dialogX, dialogY)
-					screen.DrawImage(dialogImg, dialogOp)
+					fillRect(screen, dialogX, dialogY, dialogW, dialogH, color.RGBA{30, 30, 40, 240})
 
 					prompt := "Enter width,height (e.g. 900,700):"
 					promptWidth := float64(len(prompt)) * 8
@@ -780,11 +862,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				btnW, btnH := 120.0, 40.0
 				btnX := centerX - btnW/2
 				btnY := c.Y + c.H - btnH - 24
-				btnImg := ebiten.NewImage(int(btnW), int(btnH))
-				btnImg.Fill(color.RGBA{60, 60, 120, 200})
-				btnOp := &ebiten.DrawImageOptions{}
-				btnOp.GeoM.Translate(btnX, btnY)
-				screen.DrawImage(btnImg, btnOp)
+				fillRect(screen, btnX, btnY, btnW, btnH, buttonColor)
 
 				btnText := "Back"
 				btnTextWidth := float64(len(btnText)) * 8
@@ -801,7 +879,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		screen.Fill(color.RGBA{0, 0, 0, 255})
 
 		centerX := float64(screenWidth) / 2
-		cardW, cardH := 400.0, 300.0
+		cardW, cardH := 400.0, g.deathCardHeight()
 		cardX := centerX - cardW/2
 		cardY := float64(screenHeight)/2 - cardH/2
 
@@ -809,11 +887,16 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			X: cardX, Y: cardY, W: cardW, H: cardH,
 			BgColor: color.RGBA{30, 30, 40, 220},
 			DrawContent: func(screen *ebiten.Image, c *Card) {
-				title := "Game Over"
+				title := g.endTitle
 				scoreMsg := fmt.Sprintf("Score: %d", g.deathScore)
 				highScoreMsg := ""
-				if g.username != "" {
-					highScore := scores.HighScores[g.username]
+				if g.isDaily() {
+					highScoreMsg = "Practice run - not scored"
+					if g.dailyScored {
+						highScoreMsg = "Daily score saved"
+					}
+				} else if g.username != "" {
+					highScore := boardScores(g.boardName())[g.username]
 					highScoreMsg = fmt.Sprintf("High Score: %d", highScore)
 				}
 
@@ -841,6 +924,26 @@ func (g *Game) Draw(screen *ebiten.Image) {
 					y += 36
 				}
 
+				// Run stats (centered)
+				for _, line := range g.stats.Lines() {
+					textOpStat := &text.DrawOptions{}
+					statWidth := float64(len(line)) * 8
+					textOpStat.GeoM.Translate(centerX-statWidth/2, y)
+					text.Draw(screen, line, fontFace, textOpStat)
+					y += 24
+				}
+				if g.coop() {
+					for _, p := range g.players.Items {
+						line := playerStatsLine(p)
+						textOpStat := &text.DrawOptions{}
+						statWidth := float64(len(line)) * 8
+						textOpStat.GeoM.Translate(centerX-statWidth/2, y)
+						textOpStat.ColorScale.ScaleWithColor(p.Color)
+						text.Draw(screen, line, fontFace, textOpStat)
+						y += 24
+					}
+				}
+
 				// Button Y positions (centered)
 				btnW, btnH := 120.0, 40.0
 				btnX := centerX - btnW/2
@@ -849,11 +952,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				settingsBtnY := playAgainBtnY + btnH + 16
 
 				// Main Menu button (centered)
-				menuBtnImg := ebiten.NewImage(int(btnW), int(btnH))
-				menuBtnImg.Fill(color.RGBA{60, 60, 120, 200})
-				menuBtnOp := &ebiten.DrawImageOptions{}
-				menuBtnOp.GeoM.Translate(btnX, menuBtnY)
-				screen.DrawImage(menuBtnImg, menuBtnOp)
+				fillRect(screen, btnX, menuBtnY, btnW, btnH, buttonColor)
 				menuBtnText := "Main Menu"
 				menuBtnTextWidth := float64(len(menuBtnText)) * 8
 				menuBtnTextOp := &text.DrawOptions{}
@@ -861,11 +960,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				text.Draw(screen, menuBtnText, fontFace, menuBtnTextOp)
 
 				// Play Again button (centered)
-				playAgainBtnImg := ebiten.NewImage(int(btnW), int(btnH))
-				playAgainBtnImg.Fill(color.RGBA{60, 60, 120, 200})
-				playAgainBtnOp := &ebiten.DrawImageOptions{}
-				playAgainBtnOp.GeoM.Translate(btnX, playAgainBtnY)
-				screen.DrawImage(playAgainBtnImg, playAgainBtnOp)
+				fillRect(screen, btnX, playAgainBtnY, btnW, btnH, buttonColor)
 				playAgainBtnText := "Play Again"
 				playAgainBtnTextWidth := float64(len(playAgainBtnText)) * 8
 				playAgainBtnTextOp := &text.DrawOptions{}
@@ -873,11 +968,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				text.Draw(screen, playAgainBtnText, fontFace, playAgainBtnTextOp)
 
 				// Settings button (centered)
-				settingsBtnImg := ebiten.NewImage(int(btnW), int(btnH))
-				settingsBtnImg.Fill(color.RGBA{60, 60, 120, 200})
-				settingsBtnOp := &ebiten.DrawImageOptions{}
-				settingsBtnOp.GeoM.Translate(btnX, settingsBtnY)
-				screen.DrawImage(settingsBtnImg, settingsBtnOp)
+				fillRect(screen, btnX, settingsBtnY, btnW, btnH, buttonColor)
 				settingsBtnText := "Settings"
 				settingsBtnTextWidth := float64(len(settingsBtnText)) * 8
 				settingsBtnTextOp := &text.DrawOptions{}
@@ -889,52 +980,37 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		return
 	}
 
-	// Draw background
-	if bgImage != nil {
-		x16, y16 := g.viewport.Position()
-		offsetX, offsetY := float64(-x16)/16, float64(-y16)/16
-		const repeat = 3
-		w, h := bgImage.Bounds().Dx(), bgImage.Bounds().Dy()
-		for j := 0; j < repeat; j++ {
-			for i := 0; i < repeat; i++ {
-				op := &ebiten.DrawImageOptions{}
-				op.GeoM.Translate(float64(w*i), float64(h*j))
-				op.GeoM.Translate(offsetX, offsetY)
-				screen.DrawImage(bgImage, op)
-			}
+	// The playfield and HUD go through post-processing; the debug readout
+	// doesn't
+	out := screen
+	screen = g.post.Begin(out, &g.effects)
+
+	g.camera.Frame(g.clock.Alpha, g.shakeSetting())
+	g.background.Draw(screen, g.clock.Alpha, g.camera)
+	world := g.camera.View(screen, worldLayer)
+	g.drawEntities(world, layerItems)
+	g.drawPlayers(world)
+	for _, p := range g.players.Items {
+		if !p.Out && p.Effects[PickupShield] > 0 {
+			x, y := p.Lerp(g.clock.Alpha)
+			cx, cy := float32(x+p.Size/2), float32(y+p.Size/2)
+			vector.StrokeCircle(world, cx, cy, float32(p.Size*0.8), 2, pickupInfos[PickupShield].Color, true)
 		}
 	}
-
-	// Draw player
-	if g.player != nil {
-		playerRect := ebiten.NewImage(int(g.player.Size), int(g.player.Size))
-		playerRect.Fill(color.RGBA{255, 0, 0, 255})
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(g.player.X, g.player.Y)
-		screen.DrawImage(playerRect, op)
-	}
-
-	// Draw bullets
-	for _, b := range g.bullets {
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(b.X, b.Y)
-		screen.DrawImage(bulletImg, op)
-	}
-
-	// Draw enemies
-	for _, e := range g.enemies {
-		enemyRect := ebiten.NewImage(int(e.Size), int(e.Size))
-		enemyRect.Fill(color.RGBA{0, 0, 255, 255})
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(e.X, e.Y)
-		screen.DrawImage(enemyRect, op)
-	}
-
-	// Draw enemy bullets
-	for _, eb := range g.enemyBullets {
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(eb.X, eb.Y)
-		screen.DrawImage(enemyBulletImg, op)
+	g.drawChargeMeter(world)
+	g.drawCrosshair(world)
+	g.drawBombEffect(world)
+	g.drawPopups(world)
+	g.drawEntities(world, layerShots)
+	g.drawEntities(world, layerShips)
+	g.fx.Draw(world)
+	g.drawEntities(world, layerEnemyShots)
+
+	g.camera.Present(screen, worldLayer)
+	if glow := g.post.Glow(&g.effects); glow != nil {
+		world = g.camera.View(glow, glowLayer)
+		g.drawGlowing(world)
+		g.camera.Present(glow, glowLayer)
 	}
 
 	// Draw keyboard input info
@@ -958,31 +1034,79 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	scoreX := float64(screenWidth) - textWidth - 20
 	scoreY := 10.0
 
-	rectImg := ebiten.NewImage(int(textWidth+16), int(textHeight))
-	rectImg.Fill(color.RGBA{0, 0, 0, 128})
-	rectOp := &ebiten.DrawImageOptions{}
-	rectOp.GeoM.Translate(scoreX-8, scoreY-2)
-	screen.DrawImage(rectImg, rectOp)
+	fillRect(screen, scoreX-8, scoreY-2, textWidth+16, textHeight, color.RGBA{0, 0, 0, 128})
 
 	textOpScore.GeoM.Translate(scoreX, scoreY)
 	text.Draw(screen, scoreStr, fontFace, textOpScore)
 
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("TPS: %0.2f", ebiten.ActualTPS()))
+	y := scoreY + 24
+	for _, p := range g.players.Items {
+		y = g.drawEffectsHUD(screen, p, y)
+	}
+	for _, p := range g.players.Items {
+		g.drawWeaponHUD(screen, p)
+	}
+	g.drawJoinHint(screen)
+	g.drawNetHUD(screen)
+	g.drawSpectateHUD(screen)
+	g.drawComboHUD(screen)
+	g.drawDirectorOverlay(screen)
+	g.drawModeHUD(screen)
+
+	g.post.End(out, &g.effects, g.fxState())
+	g.drawTimingReadout(out)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 	return screenWidth, screenHeight
 }
 
+// Start resets the game and begins a run.
+func (g *Game) Start() {
+	g.Reset()
+	g.dailyScored = g.isDaily() && g.startDailyAttempt()
+	g.gameState = "playing"
+}
+
 func (g *Game) Reset() {
-	g.player = NewPlayer(float64(screenWidth/2), float64(screenHeight/2))
-	g.bullets = []*Bullet{}
-	g.enemies = []*Enemy{}
-	g.enemyBullets = []*EnemyBullet{}
+	if g.isDaily() {
+		g.dailyDate = dailyDate()
+		g.seed = dailySeed(g.dailyDate)
+		g.difficulty = dailyDifficulty(g.seed)
+	} else {
+		g.seed = time.Now().UnixNano()
+		g.difficulty = difficultyByIndex(g.difficultyIdx)
+		if g.difficulty.isCustom() {
+			g.custom = g.difficulty
+		}
+	}
+	g.resetRun()
+}
+
+// resetRun starts a fresh run from g.seed and g.difficulty.
+func (g *Game) resetRun() {
+	g.rngSrc = newSimSource(g.seed)
+	g.rng = rand.New(g.rngSrc)
+	g.spawnSrc = newSimSource(g.seed ^ spawnSeedSalt)
+	g.spawnRng = rand.New(g.spawnSrc)
+	for _, s := range g.stores() {
+		s.Clear()
+	}
+	g.players.Add(g.newPlayer(0))
+	g.continues = 0
+	g.nextEnemyID = 0
+	g.stats = RunStats{}
+	g.breakCombo()
+	g.director = NewDirector()
+	g.bombFrames = 0
+	g.nextBombScore = bombMilestone
 	g.spawnCounter = 0
-	g.spawnInterval = 90
+	g.spawnInterval = g.difficulty.SpawnStart
 	g.elapsedFrames = 0
 	g.score = 0
+	g.runOver = false
+	g.hitStopTicks = 0
+	g.useStage(g.mode.Name())
 	// Don't reset username or usernameInput here!
 }
 
@@ -994,11 +1118,7 @@ type Card struct {
 }
 
 func (c *Card) Draw(screen *ebiten.Image) {
-	cardImg := ebiten.NewImage(int(c.W), int(c.H))
-	cardImg.Fill(c.BgColor)
-	cardOp := &ebiten.DrawImageOptions{}
-	cardOp.GeoM.Translate(c.X, c.Y)
-	screen.DrawImage(cardImg, cardOp)
+	fillRect(screen, c.X, c.Y, c.W, c.H, c.BgColor)
 	if c.DrawContent != nil {
 		c.DrawContent(screen, c)
 	}
@@ -1008,17 +1128,36 @@ func (c *Card) Draw(screen *ebiten.Image) {
 
 func main() {
 	loadScores()
+	loadDaily()
+	loadDropTables()
+	loadSprites()
+	loadBackgrounds()
 	ebiten.SetWindowSize(screenWidth*2, screenHeight*2)
+	// Update runs once per frame; the Clock decides how many ticks to simulate
+	ebiten.SetTPS(ebiten.SyncWithFPS)
 	ebiten.SetWindowTitle("Keyboard + Scrolling Background (Ebitengine Demo)")
 	game := &Game{
 		gameState:      "menu",
-		player:         NewPlayer(float64(screenWidth/2), float64(screenHeight/2)),
-		enemies:        []*Enemy{},
-		enemyBullets:   []*EnemyBullet{},
 		spawnInterval:  90,
+		difficulty:     difficulties[defaultDifficulty],
+		difficultyIdx:  defaultDifficulty,
+		mode:           gameModes[0],
+		background:     NewBackground(gameModes[0].Name(), 0),
+		lobby:          Lobby{Addr: defaultNetAddr, Delay: defaultNetDelay},
+		fx:             NewParticles(defaultParticleLevel),
+		particleLevel:  defaultParticleLevel,
+		effects:        defaultEffects(),
+		camera:         NewCamera(),
+		cameraOpts:     defaultCameraSettings,
 		dropdownOpen:   false,
 		selectedScreen: 0, // 0: 640x480, 1: 800x600, 2: 1024x768
 	}
+	game.audio = NewAudio(loadAudioSettings())
+	if post, err := NewPostFX(); err != nil {
+		log.Printf("post-processing disabled: %v", err)
+	} else {
+		game.post = post
+	}
 	if err := ebiten.RunGame(game); err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// gpu runs the tests inside Ebiten's game loop, so the ones that draw have a
+// graphics device. Without it they skip. On a headless Linux machine, run
+// under xvfb-run: xvfb-run go test -gpu -bench Draw
+var gpu = flag.Bool("gpu", false, "run the drawing tests and benchmarks in Ebiten's game loop")
+
+// testLoop is a game whose first Update runs the tests.
+type testLoop struct {
+	m    *testing.M
+	code int
+}
+
+func (l *testLoop) Update() error {
+	l.code = l.m.Run()
+	return ebiten.Termination
+}
+
+func (l *testLoop) Draw(*ebiten.Image) {}
+
+func (l *testLoop) Layout(int, int) (int, int) { return screenWidth, screenHeight }
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !*gpu {
+		os.Exit(m.Run())
+	}
+	l := &testLoop{m: m}
+	if err := ebiten.RunGame(l); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(l.code)
+}
+
+// needGPU skips tests that draw unless -gpu is set.
+func needGPU(tb testing.TB) {
+	tb.Helper()
+	if !*gpu {
+		tb.Skip("needs a graphics device; run with -gpu")
+	}
+}
+
+// BenchmarkDraw draws whole frames off screen. allocs/op counts Go
+// allocations per frame; Draw should create no images once warmed up.
+func BenchmarkDraw(b *testing.B) {
+	needGPU(b)
+	screen := ebiten.NewImage(screenWidth, screenHeight)
+	defer screen.Deallocate()
+
+	b.Run("menu", func(b *testing.B) {
+		g := newTestGame(1)
+		g.gameState = "menu"
+		g.usernameInput = "bench"
+		benchmarkDraw(b, g, screen)
+	})
+	b.Run("playing", func(b *testing.B) {
+		g := newTestGame(1)
+		g.gameState = "playing"
+		g.background = NewBackground(g.mode.Name(), 0)
+		g.fx = NewParticles(defaultParticleLevel)
+		g.effects = defaultEffects()
+		g.players.Items[0].Lives = 1000 // Keep the run going
+		warmUp(g, 3000)
+		benchmarkDraw(b, g, screen)
+	})
+}
+
+func benchmarkDraw(b *testing.B, g *Game, screen *ebiten.Image) {
+	g.Draw(screen) // Let caches and layers fill
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		screen.Clear()
+		g.Draw(screen)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// --- Game Modes ---
+
+// GameMode supplies the rule hooks the simulation calls into. Modes embed
+// endlessMode and override only the rules they change.
+type GameMode interface {
+	Name() string  // full name, used for leaderboards and history
+	Label() string // short name for the menu button
+
+	AllowFire() bool      // whether the player's guns (and charge shots) work
+	AllowBombs() bool     // whether bombs can be used
+	MinFireInterval() int // ticks the mode forces between shots, 0 for none
+
+	KillPoints(base int) int  // points for a kill before multipliers
+	GrazePoints(base int) int // points for a graze before multipliers
+	OnTick(g *Game)           // per-tick rules, e.g. time-based scoring
+	TimeUp(g *Game) bool      // whether the run is over without a death
+	HUD(g *Game) string       // extra HUD text, empty for none
+}
+
+type endlessMode struct{}
+
+func (endlessMode) Name() string             { return "Endless" }
+func (endlessMode) Label() string            { return "Endless" }
+func (endlessMode) AllowFire() bool          { return true }
+func (endlessMode) AllowBombs() bool         { return true }
+func (endlessMode) MinFireInterval() int     { return 0 }
+func (endlessMode) KillPoints(base int) int  { return base }
+func (endlessMode) GrazePoints(base int) int { return base }
+func (endlessMode) OnTick(g *Game)           {}
+func (endlessMode) TimeUp(g *Game) bool      { return false }
+func (endlessMode) HUD(g *Game) string       { return "" }
+
+// dailyMode is Endless with the daily seed and leaderboard.
+type dailyMode struct{ endlessMode }
+
+func (dailyMode) Name() string  { return "Daily" }
+func (dailyMode) Label() string { return "Daily" }
+
+// timeAttackMode scores as much as possible before the clock runs out.
+type timeAttackMode struct {
+	endlessMode
+	Minutes int
+}
+
+func (m timeAttackMode) Name() string  { return fmt.Sprintf("Time Attack %dm", m.Minutes) }
+func (m timeAttackMode) Label() string { return fmt.Sprintf("Time %dm", m.Minutes) }
+
+func (m timeAttackMode) remaining(g *Game) int {
+	left := m.Minutes*60*g.simRate() - g.elapsedFrames
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+func (m timeAttackMode) TimeUp(g *Game) bool { return m.remaining(g) == 0 }
+
+func (m timeAttackMode) HUD(g *Game) string {
+	secs := int(math.Ceil(g.seconds(m.remaining(g))))
+	return fmt.Sprintf("Time %d:%02d", secs/60, secs%60)
+}
+
+// survivalMode scores one point per second alive. Guns are disabled but bombs
+// still work.
+type survivalMode struct{ endlessMode }
+
+func (survivalMode) Name() string             { return "Survival" }
+func (survivalMode) Label() string            { return "Survival" }
+func (survivalMode) AllowFire() bool          { return false }
+func (survivalMode) KillPoints(base int) int  { return 0 }
+func (survivalMode) GrazePoints(base int) int { return 0 }
+
+func (survivalMode) OnTick(g *Game) {
+	if g.elapsedFrames%g.simRate() == 0 {
+		g.score++
+	}
+}
+
+func (survivalMode) HUD(g *Game) string {
+	return fmt.Sprintf("Alive %ds", int(g.seconds(g.elapsedFrames)))
+}
+
+// pacifistMode has no guns and no bombs; points come from grazing.
+type pacifistMode struct{ endlessMode }
+
+const pacifistGrazeScale = 5
+
+func (pacifistMode) Name() string             { return "Pacifist" }
+func (pacifistMode) Label() string            { return "Pacifist" }
+func (pacifistMode) AllowFire() bool          { return false }
+func (pacifistMode) AllowBombs() bool         { return false }
+func (pacifistMode) KillPoints(base int) int  { return 0 }
+func (pacifistMode) GrazePoints(base int) int { return base * pacifistGrazeScale }
+
+// oneShotMode limits the player to one shot per second.
+type oneShotMode struct{ endlessMode }
+
+func (oneShotMode) Name() string         { return "One Shot Per Second" }
+func (oneShotMode) Label() string        { return "1 Shot/s" }
+func (oneShotMode) MinFireInterval() int { return 60 }
+
+var gameModes = []GameMode{
+	endlessMode{},
+	timeAttackMode{Minutes: 2},
+	timeAttackMode{Minutes: 5},
+	survivalMode{},
+	pacifistMode{},
+	oneShotMode{},
+	dailyMode{},
+}
+
+// isDaily reports whether the selected mode is the daily challenge.
+func (g *Game) isDaily() bool {
+	_, ok := g.mode.(dailyMode)
+	return ok
+}
+
+// boardName is the leaderboard a run in this mode and difficulty goes to.
+// Endless keeps the bare difficulty name so existing boards carry over.
+func boardName(mode GameMode, difficulty string) string {
+	if _, ok := mode.(endlessMode); ok {
+		return difficulty
+	}
+	return mode.Name() + " - " + difficulty
+}
+
+// boardName is the leaderboard the current run goes to. Co-op runs have
+// their own team boards.
+func (g *Game) boardName() string {
+	board := boardName(g.mode, g.difficulty.Name)
+	if g.coop() {
+		board += " (Co-op)"
+	}
+	return board
+}
+
+// endRun marks the run over with the end card title. The simulation stops
+// there; finishRun records it once the result is final.
+func (g *Game) endRun(title string) {
+	g.deathScore = g.score
+	g.endTitle = title
+	g.runOver = true
+}
+
+// finishRun records the finished run and shows the end card.
+func (g *Game) finishRun() {
+	g.recordRun()
+	g.gameState = "dead"
+}
+
+// drawModeHUD shows the mode's extra HUD text under the combo display.
+func (g *Game) drawModeHUD(screen *ebiten.Image) {
+	line := g.mode.HUD(g)
+	if line == "" {
+		return
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(float64(screenWidth)/2-float64(len(line))*4, 36)
+	text.Draw(screen, line, fontFace, op)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// --- Network Transport ---
+
+// PacketConn is an unreliable datagram link to the other player. The rollback
+// session only talks through this, so it runs the same over UDP or through a
+// LossyConn that simulates a bad connection.
+type PacketConn interface {
+	Send(data []byte) error
+	Recv() ([]byte, bool) // next waiting packet, without blocking
+	Close() error
+}
+
+const maxPacketSize = 1024
+
+// UDPPeer is a PacketConn over UDP. A host learns the guest's address from the
+// first packet it receives.
+type UDPPeer struct {
+	conn *net.UDPConn
+	recv chan []byte
+
+	mu     sync.Mutex
+	remote *net.UDPAddr // nil until a host hears from its guest
+}
+
+// ListenUDP hosts a game on addr (e.g. ":7777").
+func ListenUDP(addr string) (*UDPPeer, error) {
+	la, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", la)
+	if err != nil {
+		return nil, err
+	}
+	return newUDPPeer(conn, nil), nil
+}
+
+// DialUDP joins the game hosted at addr (e.g. "192.168.1.20:7777").
+func DialUDP(addr string) (*UDPPeer, error) {
+	ra, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	return newUDPPeer(conn, ra), nil
+}
+
+func newUDPPeer(conn *net.UDPConn, remote *net.UDPAddr) *UDPPeer {
+	p := &UDPPeer{conn: conn, remote: remote, recv: make(chan []byte, 256)}
+	go p.readLoop()
+	return p
+}
+
+func (p *UDPPeer) readLoop() {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := p.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				close(p.recv)
+				return
+			}
+			continue
+		}
+		p.mu.Lock()
+		if p.remote == nil {
+			p.remote = from
+		}
+		ok := p.remote.IP.Equal(from.IP) && p.remote.Port == from.Port
+		p.mu.Unlock()
+		if !ok {
+			continue // Only one guest per game
+		}
+		select {
+		case p.recv <- append([]byte(nil), buf[:n]...):
+		default: // Drop if the game falls behind; the protocol resends
+		}
+	}
+}
+
+func (p *UDPPeer) Send(data []byte) error {
+	p.mu.Lock()
+	remote := p.remote
+	p.mu.Unlock()
+	if remote == nil {
+		return nil // Nobody to talk to yet
+	}
+	_, err := p.conn.WriteToUDP(data, remote)
+	return err
+}
+
+func (p *UDPPeer) Recv() ([]byte, bool) {
+	select {
+	case data, ok := <-p.recv:
+		return data, ok
+	default:
+		return nil, false
+	}
+}
+
+func (p *UDPPeer) Close() error {
+	return p.conn.Close()
+}
+
+// LossyConn wraps a PacketConn and drops or delays outgoing packets, for
+// trying the netcode on a bad connection from one machine. Which packets are
+// dropped comes from its own seeded random numbers, so a test can replay the
+// same bad connection.
+type LossyConn struct {
+	PacketConn
+	Loss    float64       // chance of dropping each packet, 0-1
+	Latency time.Duration // one-way delay added to every packet
+	Jitter  time.Duration // extra random delay, up to this much
+
+	rng *rand.Rand
+}
+
+func NewLossyConn(conn PacketConn, loss float64, latency, jitter time.Duration, seed int64) *LossyConn {
+	return &LossyConn{PacketConn: conn, Loss: loss, Latency: latency, Jitter: jitter, rng: rand.New(rand.NewSource(seed))}
+}
+
+func (c *LossyConn) Send(data []byte) error {
+	if c.rng.Float64() < c.Loss {
+		return nil
+	}
+	delay := c.Latency
+	if c.Jitter > 0 {
+		delay += time.Duration(c.rng.Int63n(int64(c.Jitter)))
+	}
+	if delay <= 0 {
+		return c.PacketConn.Send(data)
+	}
+	data = append([]byte(nil), data...)
+	time.AfterFunc(delay, func() { c.PacketConn.Send(data) })
+	return nil
+}
+
+// netSimPresets are the simulated connections selectable in the lobby.
+var netSimPresets = []struct {
+	Label   string
+	Loss    float64
+	Latency time.Duration
+	Jitter  time.Duration
+}{
+	{"Off", 0, 0, 0},
+	{"5% loss 40ms", 0.05, 40 * time.Millisecond, 10 * time.Millisecond},
+	{"15% loss 100ms", 0.15, 100 * time.Millisecond, 30 * time.Millisecond},
+}
+
+// --- Packets ---
+
+const (
+	packetHello   byte = 1 // guest -> host: let me in
+	packetWelcome byte = 2 // host -> guest: the run's settings (JSON)
+	packetInput   byte = 3 // both ways: inputs, ack and checksum
+	packetQuit    byte = 4 // both ways: leaving the game
+)
+
+// netInput is one player's Input for one frame as sent over the wire. Both
+// peers simulate with the decoded value, so rounding the aim point can't
+// make them disagree.
+type netInput struct {
+	Buttons    uint8
+	AimX, AimY int16
+}
+
+const netInputSize = 5
+
+const (
+	btnUp uint8 = 1 << iota
+	btnDown
+	btnLeft
+	btnRight
+	btnFire
+	btnCharge
+	btnSwap
+	btnBomb
+)
+
+func encodeInput(in Input) netInput {
+	var n netInput
+	bits := []struct {
+		on  bool
+		bit uint8
+	}{
+		{in.Up, btnUp}, {in.Down, btnDown}, {in.Left, btnLeft}, {in.Right, btnRight},
+		{in.Fire, btnFire}, {in.Charge, btnCharge}, {in.SwapWeapon, btnSwap}, {in.Bomb, btnBomb},
+	}
+	for _, b := range bits {
+		if b.on {
+			n.Buttons |= b.bit
+		}
+	}
+	n.AimX = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(in.AimX))))
+	n.AimY = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(in.AimY))))
+	return n
+}
+
+func (n netInput) Input() Input {
+	return Input{
+		Up:         n.Buttons&btnUp != 0,
+		Down:       n.Buttons&btnDown != 0,
+		Left:       n.Buttons&btnLeft != 0,
+		Right:      n.Buttons&btnRight != 0,
+		Fire:       n.Buttons&btnFire != 0,
+		Charge:     n.Buttons&btnCharge != 0,
+		SwapWeapon: n.Buttons&btnSwap != 0,
+		Bomb:       n.Buttons&btnBomb != 0,
+		AimX:       float64(n.AimX),
+		AimY:       float64(n.AimY),
+	}
+}
+
+// inputPacket carries the sender's inputs from Start onward, the first frame
+// of the receiver's inputs it is still missing (Ack) and, if SumFrame > 0, the
+// checksum of its confirmed state at SumFrame.
+type inputPacket struct {
+	Ack      uint32
+	Start    uint32
+	Inputs   []netInput
+	SumFrame uint32
+	Sum      uint64
+}
+
+const inputHeaderSize = 1 + 4 + 4 + 1 + 4 + 8
+
+func (p *inputPacket) Marshal() []byte {
+	buf := make([]byte, inputHeaderSize+len(p.Inputs)*netInputSize)
+	buf[0] = packetInput
+	binary.LittleEndian.PutUint32(buf[1:], p.Ack)
+	binary.LittleEndian.PutUint32(buf[5:], p.Start)
+	buf[9] = byte(len(p.Inputs))
+	binary.LittleEndian.PutUint32(buf[10:], p.SumFrame)
+	binary.LittleEndian.PutUint64(buf[14:], p.Sum)
+	off := inputHeaderSize
+	for _, in := range p.Inputs {
+		buf[off] = in.Buttons
+		binary.LittleEndian.PutUint16(buf[off+1:], uint16(in.AimX))
+		binary.LittleEndian.PutUint16(buf[off+3:], uint16(in.AimY))
+		off += netInputSize
+	}
+	return buf
+}
+
+func (p *inputPacket) Unmarshal(buf []byte) error {
+	if len(buf) < inputHeaderSize || buf[0] != packetInput {
+		return errors.New("bad input packet")
+	}
+	n := int(buf[9])
+	if len(buf) != inputHeaderSize+n*netInputSize {
+		return errors.New("bad input packet length")
+	}
+	p.Ack = binary.LittleEndian.Uint32(buf[1:])
+	p.Start = binary.LittleEndian.Uint32(buf[5:])
+	p.SumFrame = binary.LittleEndian.Uint32(buf[10:])
+	p.Sum = binary.LittleEndian.Uint64(buf[14:])
+	p.Inputs = make([]netInput, n)
+	off := inputHeaderSize
+	for i := range p.Inputs {
+		p.Inputs[i] = netInput{
+			Buttons: buf[off],
+			AimX:    int16(binary.LittleEndian.Uint16(buf[off+1:])),
+			AimY:    int16(binary.LittleEndian.Uint16(buf[off+3:])),
+		}
+		off += netInputSize
+	}
+	return nil
+}
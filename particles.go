@@ -0,0 +1,264 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// --- Particles ---
+
+// Particle is one spark of smoke or fire. Particles are purely visual and live
+// outside the simulation, so they use their own random numbers.
+type Particle struct {
+	X, Y, VX, VY float32
+	Age          float32 // in ticks at defaultSimRate, like Life
+	Life         int
+	Drag         float32 // fraction of velocity lost per tick
+	Size0, Size1 float32 // size at birth and death
+	Color0       color.RGBA
+	Color1       color.RGBA // color at death; alpha 0 fades out
+}
+
+// Emitter describes how to spawn particles.
+type Emitter struct {
+	Count         int     // particles per burst, or per second when continuous
+	Angle, Spread float64 // direction in radians and the random spread around it
+	Speed         float32
+	SpeedJitter   float32
+	Life          int
+	LifeJitter    int
+	Drag          float32
+	Size0, Size1  float32
+	Color0        color.RGBA
+	Color1        color.RGBA
+}
+
+// ContinuousEmitter spawns an emitter's particles steadily over time.
+type ContinuousEmitter struct {
+	Emitter
+	acc float64
+}
+
+// Emit spawns the share of particles for dt default ticks at (x, y).
+func (c *ContinuousEmitter) Emit(ps *Particles, x, y, dt float32) {
+	c.acc += float64(c.Count) * ps.scale * float64(dt) / defaultSimRate
+	n := int(c.acc)
+	c.acc -= float64(n)
+	ps.spawn(&c.Emitter, x, y, n)
+}
+
+// particleLevels are the choices for the particle setting.
+var particleLevels = []struct {
+	Label string
+	Max   int     // particles alive at once
+	Scale float64 // multiplier on every emitter's count
+}{
+	{"Off", 0, 0},
+	{"Low", 600, 0.35},
+	{"Medium", 2500, 0.7},
+	{"High", 8000, 1},
+}
+
+const defaultParticleLevel = 3
+
+// Particles is a fixed-size pool. Dead particles are swapped out of the live
+// range, so nothing is allocated after the pool fills up.
+type Particles struct {
+	pool  []Particle
+	live  int
+	scale float64
+	rng   *rand.Rand
+
+	vertices []ebiten.Vertex
+	indices  []uint16
+}
+
+func NewParticles(level int) *Particles {
+	ps := &Particles{rng: rand.New(rand.NewSource(1))}
+	ps.SetLevel(level)
+	return ps
+}
+
+// SetLevel resizes the pool for one of particleLevels, clearing it.
+func (ps *Particles) SetLevel(level int) {
+	l := particleLevels[level]
+	ps.pool = make([]Particle, l.Max)
+	ps.live = 0
+	ps.scale = l.Scale
+}
+
+// Burst spawns all of an emitter's particles at once.
+func (ps *Particles) Burst(e *Emitter, x, y float64) {
+	ps.spawn(e, float32(x), float32(y), int(math.Ceil(float64(e.Count)*ps.scale)))
+}
+
+func (ps *Particles) spawn(e *Emitter, x, y float32, n int) {
+	for ; n > 0 && ps.live < len(ps.pool); n-- {
+		angle := e.Angle + (ps.rng.Float64()*2-1)*e.Spread
+		speed := e.Speed + (ps.rng.Float32()*2-1)*e.SpeedJitter
+		life := e.Life
+		if e.LifeJitter > 0 {
+			life += ps.rng.Intn(e.LifeJitter + 1)
+		}
+		ps.pool[ps.live] = Particle{
+			X: x, Y: y,
+			VX:     float32(math.Cos(angle)) * speed,
+			VY:     float32(math.Sin(angle)) * speed,
+			Life:   max(life, 1),
+			Drag:   e.Drag,
+			Size0:  e.Size0,
+			Size1:  e.Size1,
+			Color0: e.Color0,
+			Color1: e.Color1,
+		}
+		ps.live++
+	}
+}
+
+// Update moves every particle by dt default ticks and retires the ones that
+// have burnt out.
+func (ps *Particles) Update(dt float32) {
+	for i := 0; i < ps.live; {
+		p := &ps.pool[i]
+		p.Age += dt
+		if p.Age >= float32(p.Life) {
+			ps.live--
+			ps.pool[i] = ps.pool[ps.live]
+			continue
+		}
+		p.X += p.VX * dt
+		p.Y += p.VY * dt
+		p.VX *= 1 - p.Drag*dt
+		p.VY *= 1 - p.Drag*dt
+		i++
+	}
+}
+
+// Live is the number of particles on screen.
+func (ps *Particles) Live() int { return ps.live }
+
+func lerp32(a, b, t float32) float32 { return a + (b-a)*t }
+
+// Draw renders the particles as batched quads with additive blending.
+func (ps *Particles) Draw(screen *ebiten.Image) {
+	const maxQuads = 65535 / 4
+	src := fallbackSprite.Image.Bounds()
+	sx, sy := float32(src.Min.X)+0.5, float32(src.Min.Y)+0.5
+	for start := 0; start < ps.live; start += maxQuads {
+		end := min(start+maxQuads, ps.live)
+		ps.vertices = ps.vertices[:0]
+		ps.indices = ps.indices[:0]
+		for i := start; i < end; i++ {
+			p := &ps.pool[i]
+			t := p.Age / float32(p.Life)
+			half := lerp32(p.Size0, p.Size1, t) / 2
+			r := lerp32(float32(p.Color0.R), float32(p.Color1.R), t) / 255
+			g := lerp32(float32(p.Color0.G), float32(p.Color1.G), t) / 255
+			b := lerp32(float32(p.Color0.B), float32(p.Color1.B), t) / 255
+			a := lerp32(float32(p.Color0.A), float32(p.Color1.A), t) / 255
+			base := uint16(len(ps.vertices))
+			for _, c := range [4][2]float32{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+				ps.vertices = append(ps.vertices, ebiten.Vertex{
+					DstX: p.X + c[0]*half, DstY: p.Y + c[1]*half,
+					SrcX: sx, SrcY: sy,
+					// Premultiplied, as DrawTriangles expects
+					ColorR: r * a, ColorG: g * a, ColorB: b * a, ColorA: a,
+				})
+			}
+			ps.indices = append(ps.indices, base, base+1, base+2, base+1, base+3, base+2)
+		}
+		op := &ebiten.DrawTrianglesOptions{Blend: ebiten.BlendLighter}
+		screen.DrawTriangles(ps.vertices, ps.indices, whiteImage, op)
+	}
+}
+
+// --- Effects ---
+
+var (
+	explosionEmitter = Emitter{
+		Count: 40, Spread: math.Pi, Speed: 2.5, SpeedJitter: 2, Life: 24, LifeJitter: 16, Drag: 0.06,
+		Size0: 5, Size1: 1, Color0: color.RGBA{255, 230, 140, 255}, Color1: color.RGBA{255, 60, 0, 0},
+	}
+	impactEmitter = Emitter{
+		Count: 8, Spread: 0.6, Speed: 2.5, SpeedJitter: 1.5, Life: 10, LifeJitter: 6, Drag: 0.1,
+		Size0: 3, Size1: 1, Color1: color.RGBA{255, 255, 255, 0},
+	}
+	deathEmitter = Emitter{
+		Count: 160, Spread: math.Pi, Speed: 3.5, SpeedJitter: 3, Life: 50, LifeJitter: 30, Drag: 0.04,
+		Size0: 6, Size1: 1, Color1: color.RGBA{255, 255, 255, 0},
+	}
+	// Ships face down the screen, so exhaust streams upward
+	thrusterEmitter = Emitter{
+		Count: 90, Angle: -math.Pi / 2, Spread: 0.25, Speed: 2, SpeedJitter: 0.8, Life: 14, LifeJitter: 6, Drag: 0.05,
+		Size0: 4, Size1: 1, Color0: color.RGBA{120, 200, 255, 220}, Color1: color.RGBA{255, 120, 40, 0},
+	}
+)
+
+// fxOn reports whether the simulation should spawn effects. Rollback replays
+// frames that already had theirs.
+func (g *Game) fxOn() bool {
+	return g.fx != nil && !g.resimulating
+}
+
+// explodeEnemy bursts an enemy into fire scaled to its size.
+func (g *Game) explodeEnemy(e *Enemy) {
+	if !g.fxOn() {
+		return
+	}
+	em := explosionEmitter
+	em.Count = int(float64(em.Count) * e.Size / 32)
+	em.Color0 = enemyKinds[e.Kind].Color
+	em.Color0.R, em.Color0.G = max(em.Color0.R, 200), max(em.Color0.G, 160)
+	g.fx.Burst(&em, e.X+e.Size/2, e.Y+e.Size/2)
+}
+
+// sparkImpact throws sparks back along a bullet's path where it hit.
+func (g *Game) sparkImpact(b *Bullet) {
+	if !g.fxOn() {
+		return
+	}
+	em := impactEmitter
+	em.Angle = math.Atan2(-b.SpeedY, -b.SpeedX)
+	em.Color0 = weaponInfos[b.Weapon].Color
+	if b.Charged {
+		em.Color0 = chargeColor
+	}
+	g.fx.Burst(&em, b.X+b.Size/2, b.Y+b.Size/2)
+}
+
+// explodePlayer marks a lost life with a large burst in the player's color.
+func (g *Game) explodePlayer(p *Player) {
+	if !g.fxOn() {
+		return
+	}
+	em := deathEmitter
+	em.Color0 = p.Color
+	if p.Lives > 0 {
+		em.Count /= 3
+	}
+	g.fx.Burst(&em, p.X+p.Size/2, p.Y+p.Size/2)
+}
+
+// updateEffects runs the thrusters, moves the particles and fades the hit
+// effects. It's called once per tick outside the simulation.
+func (g *Game) updateEffects() {
+	g.hurt = max(0, g.hurt-1)
+	if g.fx == nil {
+		return
+	}
+	dt := float32(g.tickScale())
+	for i, p := range g.players.Items {
+		if p.Out || i >= maxPlayers {
+			continue
+		}
+		th := &g.thrusters[i]
+		if th.Count == 0 {
+			th.Emitter = thrusterEmitter
+		}
+		th.Emit(g.fx, float32(p.X+p.Size/2), float32(p.Y+2), dt)
+	}
+	g.fx.Update(dt)
+}
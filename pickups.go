@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// --- Pickups ---
+
+type PickupKind int
+
+const (
+	PickupSpread PickupKind = iota
+	PickupRapidFire
+	PickupShield
+	PickupExtraLife
+	PickupBomb
+	PickupMultiplier
+	PickupMagnet
+	PickupUpgrade
+	numPickupKinds
+)
+
+type pickupInfo struct {
+	Name     string
+	Label    string // single letter drawn on the pickup
+	Color    color.RGBA
+	Duration int    // ticks the effect lasts at defaultSimRate; 0 means instant
+	Sprite   string // falls back to a colored square with Label on it
+}
+
+var pickupInfos = [numPickupKinds]pickupInfo{
+	PickupSpread:     {Name: "spread", Label: "S", Color: color.RGBA{255, 160, 0, 255}, Duration: 600, Sprite: "pickup_spread"},
+	PickupRapidFire:  {Name: "rapid", Label: "R", Color: color.RGBA{255, 60, 60, 255}, Duration: 600, Sprite: "pickup_rapid"},
+	PickupShield:     {Name: "shield", Label: "O", Color: color.RGBA{80, 200, 255, 255}, Duration: 900, Sprite: "pickup_shield"},
+	PickupExtraLife:  {Name: "life", Label: "+", Color: color.RGBA{60, 220, 60, 255}, Sprite: "pickup_life"},
+	PickupBomb:       {Name: "bomb", Label: "B", Color: color.RGBA{255, 255, 255, 255}, Sprite: "pickup_bomb"},
+	PickupMultiplier: {Name: "multiplier", Label: "x", Color: color.RGBA{255, 220, 0, 255}, Duration: 600, Sprite: "pickup_multiplier"},
+	PickupMagnet:     {Name: "magnet", Label: "M", Color: color.RGBA{200, 80, 255, 255}, Duration: 720, Sprite: "pickup_magnet"},
+	PickupUpgrade:    {Name: "upgrade", Label: "U", Color: color.RGBA{255, 255, 160, 255}, Sprite: "pickup_upgrade"},
+}
+
+func (k PickupKind) String() string {
+	if k < 0 || k >= numPickupKinds {
+		return "unknown"
+	}
+	return pickupInfos[k].Name
+}
+
+const (
+	pickupSize     = 16
+	pickupLifetime = 600 // ticks before an uncollected pickup vanishes
+	magnetRadius   = 160
+	magnetPull     = 3.0
+)
+
+type Pickup struct {
+	Transform
+	Velocity
+	Hitbox
+	Life
+	Visual
+	Kind PickupKind
+}
+
+// Attract pulls the pickup dt ticks' worth toward (tx, ty) when inside the
+// magnet radius.
+func (p *Pickup) Attract(tx, ty, dt float64) {
+	dx := tx - (p.X + p.Size/2)
+	dy := ty - (p.Y + p.Size/2)
+	dist := math.Sqrt(dx*dx + dy*dy)
+	if dist == 0 || dist > magnetRadius {
+		return
+	}
+	p.X += dx / dist * magnetPull * dt
+	p.Y += dy / dist * magnetPull * dt
+}
+
+// --- Drop Tables ---
+
+type DropEntry struct {
+	Kind   PickupKind
+	Weight int
+}
+
+type DropTable struct {
+	Chance  float64 // probability that a kill drops anything
+	Entries []DropEntry
+}
+
+var dropTables = [numEnemyKinds]DropTable{
+	EnemyGrunt: {Chance: 0.08, Entries: []DropEntry{
+		{PickupSpread, 4}, {PickupRapidFire, 4}, {PickupMultiplier, 3}, {PickupMagnet, 2}, {PickupUpgrade, 2}, {PickupShield, 1},
+	}},
+	EnemyScout: {Chance: 0.12, Entries: []DropEntry{
+		{PickupRapidFire, 4}, {PickupMagnet, 3}, {PickupMultiplier, 3},
+	}},
+	EnemyHeavy: {Chance: 0.35, Entries: []DropEntry{
+		{PickupShield, 4}, {PickupUpgrade, 4}, {PickupSpread, 3}, {PickupBomb, 2}, {PickupExtraLife, 1},
+	}},
+}
+
+// Roll returns the pickup kind to drop, if any. chanceScale adjusts the drop
+// chance for the difficulty.
+func (t DropTable) Roll(rng *rand.Rand, chanceScale float64) (PickupKind, bool) {
+	if len(t.Entries) == 0 || rng.Float64() >= t.Chance*chanceScale {
+		return 0, false
+	}
+	total := 0
+	for _, e := range t.Entries {
+		total += e.Weight
+	}
+	if total <= 0 {
+		return 0, false
+	}
+	n := rng.Intn(total)
+	for _, e := range t.Entries {
+		if n < e.Weight {
+			return e.Kind, true
+		}
+		n -= e.Weight
+	}
+	return 0, false
+}
+
+// NewPickup drops a pickup centered on (x, y). It drifts up the screen,
+// bouncing off the side walls so it stays reachable.
+func NewPickup(rng *rand.Rand, kind PickupKind, x, y float64) Pickup {
+	info := pickupInfos[kind]
+	return Pickup{
+		Transform: Transform{X: x - pickupSize/2, Y: y - pickupSize/2, Size: pickupSize},
+		Velocity:  Velocity{SpeedX: float64(rng.Intn(3) - 1), SpeedY: -1, Bounce: true},
+		Life:      Life{Max: pickupLifetime},
+		Visual:    Visual{Sprite: info.Sprite, Tint: info.Color, Label: info.Label, Layer: layerItems, Blink: true},
+		Kind:      kind,
+	}
+}
+
+// dropFile optionally overrides the built-in drop tables. Format:
+//
+//	{"grunt": {"chance": 0.1, "drops": {"spread": 3, "shield": 1}}}
+var dropFile = "drops.json"
+
+func loadDropTables() {
+	f, err := os.Open(dropFile)
+	if err != nil {
+		return // Use defaults
+	}
+	defer f.Close()
+	var raw map[string]struct {
+		Chance float64        `json:"chance"`
+		Drops  map[string]int `json:"drops"`
+	}
+	if err := json.NewDecoder(f).Decode(&raw); err != nil {
+		return
+	}
+	for k := EnemyKind(0); k < numEnemyKinds; k++ {
+		cfg, ok := raw[k.String()]
+		if !ok {
+			continue
+		}
+		table := DropTable{Chance: cfg.Chance}
+		// Iterate kinds in order so the table is the same every load
+		for pk := PickupKind(0); pk < numPickupKinds; pk++ {
+			if w := cfg.Drops[pk.String()]; w > 0 {
+				table.Entries = append(table.Entries, DropEntry{pk, w})
+			}
+		}
+		dropTables[k] = table
+	}
+}
+
+// --- Effects ---
+
+// applyPickup grants the pickup's effect to the player.
+func (g *Game) applyPickup(p *Player, kind PickupKind) {
+	g.stats.PickupsCollected++
+	p.Stats.PickupsCollected++
+	g.playSound("pickup")
+	switch kind {
+	case PickupExtraLife:
+		p.Lives++
+	case PickupUpgrade:
+		p.UpgradeWeapon()
+	case PickupBomb:
+		p.addBomb()
+	default:
+		p.Effects[kind] = g.ticks(pickupInfos[kind].Duration)
+	}
+}
+
+// updatePickups pulls pickups toward players with a magnet and hands them to
+// players who touch them. Drifting and expiring are the movement and aging
+// systems' job.
+func (g *Game) updatePickups() {
+	dt := g.tickScale()
+	for _, pk := range g.pickups.Items {
+		for _, p := range g.players.Items {
+			if !p.Out && p.Effects[PickupMagnet] > 0 {
+				pk.Attract(p.X+p.Size/2, p.Y+p.Size/2, dt)
+			}
+		}
+	}
+
+	// Players take turns in order, so player 1 wins a pickup both touch
+	g.pickupGrid.BuildFrom(&g.pickups)
+	for _, p := range g.players.Items {
+		if p.Out {
+			continue
+		}
+		pc := p.Parts().Collider()
+		for _, i := range g.pickupGrid.Query(pc) {
+			pk := g.pickups.Items[i]
+			if !pk.Gone && Overlaps(pc, pk.Parts().Collider()) {
+				g.applyPickup(p, pk.Kind)
+				pk.Gone = true
+			}
+		}
+	}
+	g.pickups.Sweep()
+}
+
+// --- HUD ---
+
+// drawEffectsHUD lists p's lives and active timed effects starting at y,
+// under a status header in co-op. It returns the y below the last line.
+func (g *Game) drawEffectsHUD(screen *ebiten.Image, p *Player, y float64) float64 {
+	drawLine := func(line string, clr color.Color) {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(screenWidth)-float64(len(line))*8-20, y)
+		op.ColorScale.ScaleWithColor(clr)
+		text.Draw(screen, line, fontFace, op)
+		y += 18
+	}
+	if g.coop() {
+		drawLine(g.playerStatusLine(p), p.Color)
+		if p.Out {
+			return y + 6
+		}
+	}
+	drawLine(fmt.Sprintf("Lives: %d", p.Lives), color.White)
+	drawLine(fmt.Sprintf("Bombs: %d", p.Bombs), pickupInfos[PickupBomb].Color)
+	for k := PickupKind(0); k < numPickupKinds; k++ {
+		if left := p.Effects[k]; left > 0 {
+			drawLine(fmt.Sprintf("%s %4.1fs", pickupInfos[k].Name, g.seconds(left)), pickupInfos[k].Color)
+		}
+	}
+	return y + 6
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDropTableRoll(t *testing.T) {
+	tests := []struct {
+		name  string
+		table DropTable
+		scale float64
+		want  map[PickupKind]bool // kinds allowed to drop; nil means nothing drops
+	}{
+		{"empty", DropTable{Chance: 1}, 1, nil},
+		{"zero chance", DropTable{Chance: 0, Entries: []DropEntry{{PickupShield, 1}}}, 1, nil},
+		{"scaled to zero", DropTable{Chance: 1, Entries: []DropEntry{{PickupShield, 1}}}, 0, nil},
+		{"all zero weight", DropTable{Chance: 1, Entries: []DropEntry{{PickupShield, 0}, {PickupBomb, 0}}}, 1, nil},
+		{"single entry", DropTable{Chance: 1, Entries: []DropEntry{{PickupMagnet, 5}}}, 1, map[PickupKind]bool{PickupMagnet: true}},
+		{"zero weight skipped", DropTable{Chance: 1, Entries: []DropEntry{{PickupSpread, 0}, {PickupShield, 1}, {PickupBomb, 0}}}, 1, map[PickupKind]bool{PickupShield: true}},
+		{"weighted", DropTable{Chance: 1, Entries: []DropEntry{{PickupSpread, 1}, {PickupShield, 3}}}, 1, map[PickupKind]bool{PickupSpread: true, PickupShield: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			seen := map[PickupKind]int{}
+			for range 1000 {
+				kind, ok := tt.table.Roll(rng, tt.scale)
+				if !ok {
+					continue
+				}
+				if !tt.want[kind] {
+					t.Fatalf("dropped %v, want one of %v", kind, tt.want)
+				}
+				seen[kind]++
+			}
+			for kind := range tt.want {
+				if seen[kind] == 0 {
+					t.Errorf("never dropped %v in 1000 rolls", kind)
+				}
+			}
+		})
+	}
+}
+
+func TestDropTableRollFollowsSeed(t *testing.T) {
+	table := dropTables[EnemyHeavy]
+	a, b := rand.New(rand.NewSource(42)), rand.New(rand.NewSource(42))
+	for i := range 500 {
+		ka, oka := table.Roll(a, 1)
+		kb, okb := table.Roll(b, 1)
+		if ka != kb || oka != okb {
+			t.Fatalf("roll %d: got %v %v and %v %v from the same seed", i, ka, oka, kb, okb)
+		}
+	}
+}
+
+func TestDropChanceMatchesTable(t *testing.T) {
+	table := DropTable{Chance: 0.25, Entries: []DropEntry{{PickupShield, 1}}}
+	rng := rand.New(rand.NewSource(1))
+	drops := 0
+	for range 10000 {
+		if _, ok := table.Roll(rng, 1); ok {
+			drops++
+		}
+	}
+	if drops < 2300 || drops > 2700 {
+		t.Errorf("%d drops in 10000 rolls at chance 0.25", drops)
+	}
+}
+
+// withDropFile loads data as the drop table override and restores the
+// built-in tables afterwards.
+func withDropFile(t *testing.T, data string) {
+	t.Helper()
+	saved, savedFile := dropTables, dropFile
+	t.Cleanup(func() { dropTables, dropFile = saved, savedFile })
+	dropFile = filepath.Join(t.TempDir(), "drops.json")
+	if err := os.WriteFile(dropFile, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loadDropTables()
+}
+
+func TestLoadDropTables(t *testing.T) {
+	scout := dropTables[EnemyScout]
+	withDropFile(t, `{"grunt": {"chance": 0.5, "drops": {"shield": 2, "spread": 3, "bomb": 0, "lasers": 9}}}`)
+	got := dropTables[EnemyGrunt]
+	want := DropTable{Chance: 0.5, Entries: []DropEntry{{PickupSpread, 3}, {PickupShield, 2}}}
+	if got.Chance != want.Chance || len(got.Entries) != len(want.Entries) {
+		t.Fatalf("grunt table = %+v, want %+v", got, want)
+	}
+	for i := range want.Entries {
+		if got.Entries[i] != want.Entries[i] {
+			t.Fatalf("grunt table = %+v, want %+v", got, want)
+		}
+	}
+	if dropTables[EnemyScout].Chance != scout.Chance || len(dropTables[EnemyScout].Entries) != len(scout.Entries) {
+		t.Errorf("scout table changed to %+v though the file doesn't mention it", dropTables[EnemyScout])
+	}
+}
+
+func TestLoadDropTablesKeepsDefaultsOnBadFile(t *testing.T) {
+	grunt := dropTables[EnemyGrunt]
+	withDropFile(t, `{"grunt": {"chance": "lots"`)
+	if got := dropTables[EnemyGrunt]; got.Chance != grunt.Chance || len(got.Entries) != len(grunt.Entries) {
+		t.Errorf("grunt table = %+v after a bad file, want the default", got)
+	}
+}
+
+// dropPickup puts a pickup of kind on top of p.
+func dropPickup(g *Game, p *Player, kind PickupKind) {
+	g.pickups.Add(NewPickup(g.rng, kind, p.X+p.Size/2, p.Y+p.Size/2))
+}
+
+func TestPickupCollection(t *testing.T) {
+	g := newTestGame(1)
+	p := g.players.Items[0]
+	lives, bombs, level := p.Lives, p.Bombs, p.WeaponLevels[p.Weapon]
+
+	for _, kind := range []PickupKind{PickupExtraLife, PickupBomb, PickupUpgrade, PickupShield} {
+		dropPickup(g, p, kind)
+	}
+	g.updatePickups()
+
+	if g.pickups.Len() != 0 {
+		t.Errorf("%d pickups left on the player", g.pickups.Len())
+	}
+	if p.Lives != lives+1 {
+		t.Errorf("lives = %d, want %d", p.Lives, lives+1)
+	}
+	if p.Bombs != bombs+1 {
+		t.Errorf("bombs = %d, want %d", p.Bombs, bombs+1)
+	}
+	if got := p.WeaponLevels[p.Weapon]; got != level+1 {
+		t.Errorf("weapon level = %d, want %d", got, level+1)
+	}
+	if got := p.Effects[PickupShield]; got != pickupInfos[PickupShield].Duration {
+		t.Errorf("shield = %d ticks, want %d", got, pickupInfos[PickupShield].Duration)
+	}
+	if g.stats.PickupsCollected != 4 {
+		t.Errorf("stats count %d pickups, want 4", g.stats.PickupsCollected)
+	}
+}
+
+func TestPickupEffectExpires(t *testing.T) {
+	for i, rate := range simRates {
+		g := newTestGame(1)
+		g.simRateIdx = i
+		p := g.players.Items[0]
+		g.applyPickup(p, PickupRapidFire)
+		ticks := g.ticks(pickupInfos[PickupRapidFire].Duration)
+		var idle [maxPlayers]Input
+		for range ticks - 1 {
+			g.playerSystem(idle)
+		}
+		if p.Effects[PickupRapidFire] != 1 {
+			t.Errorf("%d Hz: %d ticks left one tick before the end", rate, p.Effects[PickupRapidFire])
+		}
+		g.playerSystem(idle)
+		if p.Effects[PickupRapidFire] != 0 {
+			t.Errorf("%d Hz: still active after %d ticks", rate, ticks)
+		}
+	}
+}
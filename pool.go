@@ -0,0 +1,52 @@
+package main
+
+// --- Entity Pools ---
+
+// Pool is a free list of entities. Removed bullets, enemies and the rest go
+// back to their pool and are handed out again, so once a run warms up the
+// simulation stops allocating.
+type Pool[T any] struct {
+	free []*T
+}
+
+// Get returns a zeroed entity, reusing a freed one if there is any.
+func (p *Pool[T]) Get() *T {
+	n := len(p.free)
+	if n == 0 {
+		return new(T)
+	}
+	v := p.free[n-1]
+	p.free = p.free[:n-1]
+	var zero T
+	*v = zero
+	return v
+}
+
+// Put frees v. Nothing may hold on to it afterwards.
+func (p *Pool[T]) Put(v *T) {
+	p.free = append(p.free, v)
+}
+
+// compact filters s in place, keeping order, and frees what keep rejects.
+func compact[T any](s []*T, pool *Pool[T], keep func(*T) bool) []*T {
+	n := 0
+	for _, v := range s {
+		if keep(v) {
+			s[n] = v
+			n++
+		} else {
+			pool.Put(v)
+		}
+	}
+	clear(s[n:])
+	return s[:n]
+}
+
+// release frees every entity in s and empties it.
+func release[T any](s []*T, pool *Pool[T]) []*T {
+	for _, v := range s {
+		pool.Put(v)
+	}
+	clear(s)
+	return s[:0]
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// --- Post-Processing ---
+
+type Effect int
+
+const (
+	EffectBloom Effect = iota
+	EffectChromatic
+	EffectCRT
+	EffectVignette
+	EffectDamageFlash
+	numEffects
+)
+
+// effectInfos are the post-processing effects in the order they're applied,
+// with their default settings.
+var effectInfos = [numEffects]struct {
+	Name      string
+	On        bool
+	Intensity float64
+	Shader    string // Kage source; bloom uses blurShader
+}{
+	EffectBloom:       {"Bloom", true, 0.6, ""},
+	EffectChromatic:   {"Aberration", true, 0.5, chromaticShader},
+	EffectCRT:         {"CRT", false, 0.5, crtShader},
+	EffectVignette:    {"Vignette", true, 0.4, vignetteShader},
+	EffectDamageFlash: {"Hit Flash", true, 0.6, damageFlashShader},
+}
+
+// EffectSetting is the player's choice for one effect. Intensity is 0-1.
+type EffectSetting struct {
+	On        bool
+	Intensity float64
+}
+
+func defaultEffects() [numEffects]EffectSetting {
+	var s [numEffects]EffectSetting
+	for i, info := range effectInfos {
+		s[i] = EffectSetting{On: info.On, Intensity: info.Intensity}
+	}
+	return s
+}
+
+// FXState is what the effects react to in the frame being drawn.
+type FXState struct {
+	Hurt float64 // 1 just after a player is hit, fading to 0
+}
+
+// PostFX renders the playfield off screen and runs it through a chain of
+// shaders on the way to the screen. Effects that are off or have nothing to
+// show are skipped, and with everything off the playfield draws straight to
+// the screen.
+type PostFX struct {
+	shaders [numEffects]*ebiten.Shader
+	blur    *ebiten.Shader
+
+	scene, glow *ebiten.Image
+	ping, pong  *ebiten.Image
+}
+
+// NewPostFX compiles the effect shaders.
+func NewPostFX() (*PostFX, error) {
+	p := &PostFX{}
+	var err error
+	if p.blur, err = ebiten.NewShader([]byte(blurShader)); err != nil {
+		return nil, fmt.Errorf("blur shader: %w", err)
+	}
+	for i, info := range effectInfos {
+		if info.Shader == "" {
+			continue
+		}
+		if p.shaders[i], err = ebiten.NewShader([]byte(info.Shader)); err != nil {
+			return nil, fmt.Errorf("%s shader: %w", info.Name, err)
+		}
+	}
+	return p, nil
+}
+
+func anyEffectOn(settings *[numEffects]EffectSetting) bool {
+	for _, s := range settings {
+		if s.On && s.Intensity > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fit makes *img a clear w x h image, reallocating it if the size changed.
+func fit(img **ebiten.Image, w, h int) {
+	if *img != nil && (*img).Bounds().Dx() == w && (*img).Bounds().Dy() == h {
+		(*img).Clear()
+		return
+	}
+	if *img != nil {
+		(*img).Deallocate()
+	}
+	*img = ebiten.NewImage(w, h)
+}
+
+// Begin returns the image to draw the playfield on: an off-screen target if
+// any effect is on, otherwise screen itself.
+func (p *PostFX) Begin(screen *ebiten.Image, settings *[numEffects]EffectSetting) *ebiten.Image {
+	if p == nil || !anyEffectOn(settings) {
+		return screen
+	}
+	w, h := screen.Bounds().Dx(), screen.Bounds().Dy()
+	fit(&p.scene, w, h)
+	if settings[EffectBloom].On {
+		fit(&p.glow, w, h)
+	}
+	return p.scene
+}
+
+// Glow returns the image to draw glowing things on, in addition to the
+// scene, or nil if bloom is off.
+func (p *PostFX) Glow(settings *[numEffects]EffectSetting) *ebiten.Image {
+	if p == nil || !anyEffectOn(settings) || !settings[EffectBloom].On {
+		return nil
+	}
+	return p.glow
+}
+
+// End runs the effects over what was drawn since Begin and draws the result
+// on screen.
+func (p *PostFX) End(screen *ebiten.Image, settings *[numEffects]EffectSetting, state FXState) {
+	if p == nil || !anyEffectOn(settings) {
+		return
+	}
+	p.Apply(screen, p.scene, p.Glow(settings), settings, state)
+}
+
+// Apply draws src through the effect chain onto dst. glow, if not nil, is
+// blurred and added for bloom. src and glow are drawn over, so they can't be
+// used afterwards. It works on any images, not just the screen.
+func (p *PostFX) Apply(dst, src, glow *ebiten.Image, settings *[numEffects]EffectSetting, state FXState) {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	fit(&p.ping, w, h)
+	fit(&p.pong, w, h)
+
+	if s := settings[EffectBloom]; s.On && s.Intensity > 0 && glow != nil {
+		// Two blur passes each way, the second wider, for a soft halo
+		for _, radius := range []float32{1.5, 3.5} {
+			p.pass(p.ping, glow, p.blur, map[string]any{"Dir": []float32{radius, 0}})
+			p.pass(glow, p.ping, p.blur, map[string]any{"Dir": []float32{0, radius}})
+		}
+		op := &ebiten.DrawImageOptions{Blend: ebiten.BlendLighter}
+		op.ColorScale.Scale(float32(s.Intensity*2), float32(s.Intensity*2), float32(s.Intensity*2), 1)
+		src.DrawImage(glow, op)
+	}
+
+	free := [2]*ebiten.Image{p.ping, p.pong}
+	for i := EffectChromatic; i < numEffects; i++ {
+		s := settings[i]
+		if !s.On || s.Intensity <= 0 || p.shaders[i] == nil {
+			continue
+		}
+		amount := s.Intensity
+		if i == EffectChromatic || i == EffectDamageFlash {
+			amount *= state.Hurt
+			if amount <= 0 {
+				continue
+			}
+		}
+		out := free[0]
+		p.pass(out, src, p.shaders[i], map[string]any{"Amount": float32(amount)})
+		// The old source is free for the next pass
+		if src == p.ping || src == p.pong {
+			free[0] = src
+		} else {
+			free[0] = free[1]
+		}
+		src = out
+	}
+	dst.DrawImage(src, nil)
+}
+
+// pass replaces dst with src run through shader.
+func (p *PostFX) pass(dst, src *ebiten.Image, shader *ebiten.Shader, uniforms map[string]any) {
+	b := src.Bounds()
+	op := &ebiten.DrawRectShaderOptions{Uniforms: uniforms, Blend: ebiten.BlendCopy}
+	op.Images[0] = src
+	dst.DrawRectShader(b.Dx(), b.Dy(), shader, op)
+}
+
+// --- Hit Reaction ---
+
+// hurtFrames is how long the hit flash and aberration take to fade.
+const hurtFrames = 30
+
+// flashDamage starts the hit effects when a player takes damage.
+func (g *Game) flashDamage() {
+	if !g.resimulating {
+		g.hurt = g.ticks(hurtFrames)
+	}
+}
+
+func (g *Game) fxState() FXState {
+	return FXState{Hurt: float64(g.hurt) / float64(g.ticks(hurtFrames))}
+}
+
+// --- Shaders ---
+
+// blurShader is one direction of a separable Gaussian blur. Dir is the step
+// between samples in pixels.
+const blurShader = `//kage:unit pixels
+package main
+
+var Dir vec2
+
+func Fragment(dstPos vec4, srcPos vec2, color vec4) vec4 {
+	c := imageSrc0At(srcPos) * 0.227027
+	c += (imageSrc0At(srcPos+Dir*1.384615) + imageSrc0At(srcPos-Dir*1.384615)) * 0.316216
+	c += (imageSrc0At(srcPos+Dir*3.230769) + imageSrc0At(srcPos-Dir*3.230769)) * 0.070270
+	return c
+}
+`
+
+// chromaticShader splits red and blue apart, more toward the edges.
+const chromaticShader = `//kage:unit pixels
+package main
+
+var Amount float
+
+func Fragment(dstPos vec4, srcPos vec2, color vec4) vec4 {
+	size := imageSrc0Size()
+	off := (srcPos - imageSrc0Origin() - size/2) / size * Amount * 16
+	c := imageSrc0At(srcPos)
+	return vec4(imageSrc0At(srcPos+off).r, c.g, imageSrc0At(srcPos-off).b, c.a)
+}
+`
+
+// crtShader darkens every other row and adds an RGB aperture mask.
+const crtShader = `//kage:unit pixels
+package main
+
+var Amount float
+
+func Fragment(dstPos vec4, srcPos vec2, color vec4) vec4 {
+	pos := srcPos - imageSrc0Origin()
+	c := imageSrc0At(srcPos)
+	scan := 1 - Amount*0.45*(0.5+0.5*sin(pos.y*3.141593))
+	mask := vec3(1)
+	col := mod(floor(pos.x), 3)
+	if col < 1 {
+		mask = vec3(1, 1-Amount*0.3, 1-Amount*0.3)
+	} else if col < 2 {
+		mask = vec3(1-Amount*0.3, 1, 1-Amount*0.3)
+	} else {
+		mask = vec3(1-Amount*0.3, 1-Amount*0.3, 1)
+	}
+	return vec4(c.rgb*mask*scan, c.a)
+}
+`
+
+// vignetteShader darkens toward the corners.
+const vignetteShader = `//kage:unit pixels
+package main
+
+var Amount float
+
+func Fragment(dstPos vec4, srcPos vec2, color vec4) vec4 {
+	uv := (srcPos-imageSrc0Origin())/imageSrc0Size() - 0.5
+	d := length(uv) * 1.414
+	c := imageSrc0At(srcPos)
+	return vec4(c.rgb*(1-Amount*smoothstep(0.35, 1.0, d)), c.a)
+}
+`
+
+// damageFlashShader tints the screen red, strongest at the edges.
+const damageFlashShader = `//kage:unit pixels
+package main
+
+var Amount float
+
+func Fragment(dstPos vec4, srcPos vec2, color vec4) vec4 {
+	uv := (srcPos-imageSrc0Origin())/imageSrc0Size() - 0.5
+	edge := 0.35 + 0.65*smoothstep(0.2, 0.7, length(uv))
+	c := imageSrc0At(srcPos)
+	return vec4(mix(c.rgb, vec3(0.9, 0.05, 0.05)*c.a, Amount*edge*0.6), c.a)
+}
+`
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestShadersCompile(t *testing.T) {
+	sources := map[string]string{"blur": blurShader}
+	for _, info := range effectInfos {
+		if info.Shader != "" {
+			sources[info.Name] = info.Shader
+		}
+	}
+	for name, src := range sources {
+		s, err := ebiten.NewShader([]byte(src))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		s.Deallocate()
+	}
+}
+
+// onlyEffect turns on one effect at full intensity and the rest off.
+func onlyEffect(e Effect) *[numEffects]EffectSetting {
+	var s [numEffects]EffectSetting
+	s[e] = EffectSetting{On: true, Intensity: 1}
+	return &s
+}
+
+func TestEffectChainRenders(t *testing.T) {
+	needGPU(t)
+	post, err := NewPostFX()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const w, h = 64, 64
+	grey := color.RGBA{128, 128, 128, 255}
+	render := func(settings *[numEffects]EffectSetting, state FXState, glowing bool) *ebiten.Image {
+		src, dst := ebiten.NewImage(w, h), ebiten.NewImage(w, h)
+		src.Fill(grey)
+		var glow *ebiten.Image
+		if glowing {
+			glow = ebiten.NewImage(w, h)
+			glow.Set(w/2, h/2, color.White)
+		}
+		post.Apply(dst, src, glow, settings, state)
+		return dst
+	}
+	rgba := func(img *ebiten.Image, x, y int) color.RGBA {
+		return img.At(x, y).(color.RGBA)
+	}
+
+	if got := rgba(render(&[numEffects]EffectSetting{}, FXState{}, false), w/2, h/2); got != grey {
+		t.Errorf("with everything off got %v, want the scene unchanged", got)
+	}
+
+	vignette := render(onlyEffect(EffectVignette), FXState{}, false)
+	if corner, middle := rgba(vignette, 0, 0), rgba(vignette, w/2, h/2); corner.R >= middle.R {
+		t.Errorf("vignette: corner %v isn't darker than the middle %v", corner, middle)
+	}
+
+	flash := rgba(render(onlyEffect(EffectDamageFlash), FXState{Hurt: 1}, false), 0, 0)
+	if flash.R <= flash.G {
+		t.Errorf("hit flash: got %v, want a red tint", flash)
+	}
+	if got := rgba(render(onlyEffect(EffectDamageFlash), FXState{}, false), 0, 0); got != grey {
+		t.Errorf("hit flash with no hurt: got %v, want the scene unchanged", got)
+	}
+
+	crt := render(onlyEffect(EffectCRT), FXState{}, false)
+	if a, b := rgba(crt, 0, 0), rgba(crt, 1, 0); a == b {
+		t.Errorf("CRT: neighbouring columns both %v, want the aperture mask", a)
+	}
+
+	if got := rgba(render(onlyEffect(EffectBloom), FXState{}, true), w/2+2, h/2); got.R <= grey.R {
+		t.Errorf("bloom: got %v next to the glowing pixel, want brighter than %v", got, grey)
+	}
+
+	all := defaultEffects()
+	for i := range all {
+		all[i] = EffectSetting{On: true, Intensity: 1}
+	}
+	if got := rgba(render(&all, FXState{Hurt: 1}, true), w/2, h/2); got.A != 255 {
+		t.Errorf("full chain: got %v, want an opaque picture", got)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"hash/fnv"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// --- Procedural Backgrounds ---
+
+// proceduralStage is the stage used for every mode when the procedural
+// background setting is on. backgroundFile can override it like any other.
+const proceduralStage = "procedural"
+
+func init() {
+	stages[proceduralStage] = stageDef{Layers: []bgLayerDef{
+		{Image: "nebula", Speed: 0.1, Direction: 270, Scale: ptr(2.0)},
+		{Image: "stars_far", Speed: 0.25, Direction: 270},
+		{Image: "planets", Speed: 0.4, Direction: 270},
+		{Image: "stars", Speed: 0.8, Direction: 270},
+		{Image: "stars_twinkle", Speed: 0.8, Direction: 270, Twinkle: 0.8},
+		{Image: "stars_twinkle", Speed: 0.8, Direction: 270, Twinkle: 0.8},
+	}}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// proceduralImages generate layer images that can be used in place of a PNG
+// name in any stage. Each returns a texture that tiles seamlessly.
+var proceduralImages = map[string]func(r *rand.Rand) *image.RGBA{
+	"stars":         func(r *rand.Rand) *image.RGBA { return genStars(r, 512, 180, 0.9) },
+	"stars_far":     func(r *rand.Rand) *image.RGBA { return genStars(r, 512, 400, 0.45) },
+	"stars_twinkle": func(r *rand.Rand) *image.RGBA { return genStars(r, 512, 60, 1) },
+	"nebula":        genNebula,
+	"planets":       genPlanets,
+}
+
+// stringSeed hashes s into a seed, so different stages and layers built from
+// the same run seed come out different.
+func stringSeed(s string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return int64(h.Sum64())
+}
+
+// generateLayer builds a procedural layer image, or returns nil if name
+// isn't one.
+func generateLayer(name string, seed int64) *ebiten.Image {
+	gen, ok := proceduralImages[name]
+	if !ok {
+		return nil
+	}
+	return ebiten.NewImageFromImage(gen(rand.New(rand.NewSource(seed))))
+}
+
+// starColors are the tints stars are picked from, mostly white.
+var starColors = []color.RGBA{
+	{255, 255, 255, 255}, {255, 255, 255, 255}, {255, 255, 255, 255},
+	{180, 200, 255, 255}, {255, 230, 180, 255}, {255, 200, 200, 255},
+}
+
+// genStars scatters n stars over a size x size texture. The brightest get a
+// small cross of glow.
+func genStars(r *rand.Rand, size, n int, brightness float64) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for range n {
+		x, y := r.Intn(size), r.Intn(size)
+		c := starColors[r.Intn(len(starColors))]
+		b := brightness * (0.35 + 0.65*r.Float64()*r.Float64())
+		blendPixel(img, x, y, c, b)
+		if b > 0.7 {
+			for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				blendPixel(img, x+d[0], y+d[1], c, b*0.35)
+			}
+		}
+	}
+	return img
+}
+
+// blendPixel adds c at strength a (0-1) to the pixel at (x, y), wrapping at
+// the edges so the texture tiles.
+func blendPixel(img *image.RGBA, x, y int, c color.RGBA, a float64) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	x, y = (x%w+w)%w, (y%h+h)%h
+	i := img.PixOffset(x, y)
+	p := img.Pix[i : i+4 : i+4]
+	add := func(dst *uint8, v float64) {
+		*dst = uint8(min(255, float64(*dst)+v))
+	}
+	// Premultiplied alpha
+	add(&p[0], float64(c.R)*a)
+	add(&p[1], float64(c.G)*a)
+	add(&p[2], float64(c.B)*a)
+	add(&p[3], 255*a)
+}
+
+// noise is tileable value noise: random values on a lattice that wraps every
+// period cells, smoothly interpolated between.
+type noise struct {
+	period int
+	values []float64
+}
+
+func newNoise(r *rand.Rand, period int) *noise {
+	n := &noise{period: period, values: make([]float64, period*period)}
+	for i := range n.values {
+		n.values[i] = r.Float64()
+	}
+	return n
+}
+
+func (n *noise) at(x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := smoothstep(x-x0), smoothstep(y-y0)
+	p := n.period
+	ix, iy := (int(x0)%p+p)%p, (int(y0)%p+p)%p
+	ix1, iy1 := (ix+1)%p, (iy+1)%p
+	v := func(x, y int) float64 { return n.values[y*p+x] }
+	top := v(ix, iy) + (v(ix1, iy)-v(ix, iy))*fx
+	bottom := v(ix, iy1) + (v(ix1, iy1)-v(ix, iy1))*fx
+	return top + (bottom-top)*fy
+}
+
+func smoothstep(t float64) float64 { return t * t * (3 - 2*t) }
+
+// fbm sums octaves of tileable noise, each twice as fine and half as strong,
+// for a cloudy pattern in 0-1 over a size x size tile.
+func fbm(r *rand.Rand, size, basePeriod, octaves int) func(x, y int) float64 {
+	layers := make([]*noise, octaves)
+	for i := range layers {
+		layers[i] = newNoise(r, basePeriod<<i)
+	}
+	return func(x, y int) float64 {
+		sum, amp, total := 0.0, 1.0, 0.0
+		for _, n := range layers {
+			scale := float64(n.period) / float64(size)
+			sum += n.at(float64(x)*scale, float64(y)*scale) * amp
+			total += amp
+			amp /= 2
+		}
+		return sum / total
+	}
+}
+
+// genNebula is soft clouds in two seeded colors over transparent space.
+func genNebula(r *rand.Rand) *image.RGBA {
+	const size = 256
+	density := fbm(r, size, 4, 5)
+	tint := fbm(r, size, 2, 3)
+	c1, c2 := randomHue(r, 0.8), randomHue(r, 0.6)
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			d := density(x, y)
+			a := math.Max(0, math.Min(1, (d-0.42)/0.35))
+			a = a * a * 0.55
+			t := tint(x, y)
+			i := img.PixOffset(x, y)
+			img.Pix[i+0] = uint8((float64(c1.R)*(1-t) + float64(c2.R)*t) * a)
+			img.Pix[i+1] = uint8((float64(c1.G)*(1-t) + float64(c2.G)*t) * a)
+			img.Pix[i+2] = uint8((float64(c1.B)*(1-t) + float64(c2.B)*t) * a)
+			img.Pix[i+3] = uint8(255 * a)
+		}
+	}
+	return img
+}
+
+// randomHue is a fully saturated color of random hue at brightness v.
+func randomHue(r *rand.Rand, v float64) color.RGBA {
+	h := r.Float64() * 6
+	f := h - math.Floor(h)
+	var rf, gf, bf float64
+	switch int(h) {
+	case 0:
+		rf, gf, bf = 1, f, 0
+	case 1:
+		rf, gf, bf = 1-f, 1, 0
+	case 2:
+		rf, gf, bf = 0, 1, f
+	case 3:
+		rf, gf, bf = 0, 1-f, 1
+	case 4:
+		rf, gf, bf = f, 0, 1
+	default:
+		rf, gf, bf = 1, 0, 1-f
+	}
+	return color.RGBA{uint8(rf * v * 255), uint8(gf * v * 255), uint8(bf * v * 255), 255}
+}
+
+// genPlanets places one or two distant planets, lit from the upper left and
+// banded with noise, on a large transparent tile so they come by rarely.
+func genPlanets(r *rand.Rand) *image.RGBA {
+	const size = 1024
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	count := 1 + r.Intn(2)
+	for i := range count {
+		radius := 18 + r.Float64()*42
+		// Each planet gets its own band of the tile so they never overlap
+		cx := radius + r.Float64()*(size-2*radius)
+		cy := float64(i*size/count) + radius + r.Float64()*(float64(size/count)-2*radius)
+		base := randomHue(r, 0.5+0.4*r.Float64())
+		bands := newNoise(r, 8)
+		for y := int(cy - radius - 2); y <= int(cy+radius+2); y++ {
+			for x := int(cx - radius - 2); x <= int(cx+radius+2); x++ {
+				dx, dy := (float64(x)+0.5-cx)/radius, (float64(y)+0.5-cy)/radius
+				d := math.Sqrt(dx*dx + dy*dy)
+				if d > 1.05 {
+					continue
+				}
+				if d > 1 { // Thin atmosphere at the rim
+					blendPixel(img, x, y, base, (1.05-d)/0.05*0.4)
+					continue
+				}
+				// Sphere normal dotted with a light from the upper left
+				nz := math.Sqrt(1 - d*d)
+				light := math.Max(0.08, -dx*0.5-dy*0.5+nz*0.7)
+				band := 0.75 + 0.5*bands.at(dy*3+4, dx*0.5+4)
+				shade := math.Min(1, light*band)
+				c := color.RGBA{
+					uint8(float64(base.R) * shade),
+					uint8(float64(base.G) * shade),
+					uint8(float64(base.B) * shade),
+					255,
+				}
+				edge := math.Min(1, (1-d)*radius) // Antialias the edge
+				blendPixel(img, x, y, c, edge)
+			}
+		}
+	}
+	return img
+}
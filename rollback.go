@@ -0,0 +1,425 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// --- Deterministic Randomness ---
+
+// simSource is a splitmix64 generator for math/rand. Unlike the standard
+// source its whole state is one number, so a snapshot can copy it.
+type simSource struct {
+	state uint64
+}
+
+func newSimSource(seed int64) *simSource {
+	return &simSource{state: uint64(seed)}
+}
+
+func (s *simSource) Seed(seed int64) { s.state = uint64(seed) }
+
+func (s *simSource) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *simSource) Int63() int64 { return int64(s.Uint64() >> 1) }
+
+// spawnSeedSalt sets the spawn stream apart from the main one on the same
+// seed.
+const spawnSeedSalt = 0x5eed5a17
+
+// --- Snapshots ---
+
+// simState is a deep copy of everything step reads and writes, so the run can
+// be rewound to an earlier frame and played forward again.
+type simState struct {
+	entities [numStores]entityList // a copy of each store, in g.stores order
+	rng      simSource
+	spawnRng simSource
+
+	nextEnemyID   int
+	spawnCounter  int
+	spawnInterval int
+	elapsedFrames int
+	score         int
+	stats         RunStats
+	combo         int
+	comboTimer    int
+	bombFrames    int
+	bombX, bombY  float64
+	nextBombScore int
+	director      Director
+	continues     int
+
+	runOver    bool
+	deathScore int
+	endTitle   string
+}
+
+func (g *Game) saveState() simState {
+	s := simState{
+		rng:           *g.rngSrc,
+		spawnRng:      *g.spawnSrc,
+		nextEnemyID:   g.nextEnemyID,
+		spawnCounter:  g.spawnCounter,
+		spawnInterval: g.spawnInterval,
+		elapsedFrames: g.elapsedFrames,
+		score:         g.score,
+		stats:         g.stats,
+		combo:         g.combo,
+		comboTimer:    g.comboTimer,
+		bombFrames:    g.bombFrames,
+		bombX:         g.bombX,
+		bombY:         g.bombY,
+		nextBombScore: g.nextBombScore,
+		director:      g.director,
+		continues:     g.continues,
+		runOver:       g.runOver,
+		deathScore:    g.deathScore,
+		endTitle:      g.endTitle,
+	}
+	for i, st := range g.stores() {
+		s.entities[i] = st.save()
+	}
+	return s
+}
+
+func (g *Game) loadState(s *simState) {
+	for i, st := range g.stores() {
+		st.load(s.entities[i])
+	}
+	*g.rngSrc = s.rng
+	*g.spawnSrc = s.spawnRng
+	g.nextEnemyID = s.nextEnemyID
+	g.spawnCounter = s.spawnCounter
+	g.spawnInterval = s.spawnInterval
+	g.elapsedFrames = s.elapsedFrames
+	g.score = s.score
+	g.stats = s.stats
+	g.combo = s.combo
+	g.comboTimer = s.comboTimer
+	g.bombFrames = s.bombFrames
+	g.bombX, g.bombY = s.bombX, s.bombY
+	g.nextBombScore = s.nextBombScore
+	g.director = s.director
+	g.continues = s.continues
+	g.runOver = s.runOver
+	g.deathScore = s.deathScore
+	g.endTitle = s.endTitle
+}
+
+// checksum hashes the state that matters for gameplay: the counters step
+// reads and every entity. Peers compare it to catch a desync.
+func (s *simState) checksum() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	putInt := func(v int) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		h.Write(buf[:])
+	}
+	putInt(int(s.rng.state))
+	putInt(int(s.spawnRng.state))
+	putInt(s.elapsedFrames)
+	putInt(s.score)
+	putInt(s.nextEnemyID)
+	// Every entity, field by field, as the spectator stream encodes it
+	for _, list := range s.entities {
+		data, _ := json.Marshal(list)
+		h.Write(data)
+	}
+	return h.Sum64()
+}
+
+// --- Rollback Session ---
+
+const (
+	rollbackWindow  = 64  // frames of inputs and snapshots kept
+	maxPrediction   = 8   // frames we may run ahead of the other player's inputs
+	maxInputDelay   = 6   // highest input delay selectable in the lobby
+	checksumEvery   = 30  // frames between desync checks
+	netTimeout      = 300 // ticks without a packet before giving up
+	maxInputsPerPkt = 64
+)
+
+// Session runs GGPO-style rollback between two peers. Each side simulates
+// every frame right away, guessing that the other player keeps doing what
+// they last did. When their real input for a frame arrives and differs from
+// the guess, the run is rewound to that frame and played forward again.
+type Session struct {
+	conn  PacketConn
+	Local int // index of the player on this machine
+	Delay int // frames between sampling a local input and using it
+
+	Frame      int // next frame to simulate
+	localNext  int // first frame without a local input
+	remoteNext int // first frame without the remote input
+	remoteAck  int // first frame of our inputs the remote still needs
+
+	inputs     [maxPlayers][rollbackWindow]netInput
+	used       [rollbackWindow]netInput // remote input each frame was simulated with
+	states     [rollbackWindow]simState // state at the start of each frame
+	rollbackTo int                      // earliest frame to resimulate, -1 for none
+
+	sums       [rollbackWindow]uint64 // our checksums, by frame
+	sumNext    int                    // next checksum frame to compute
+	lastSum    int                    // frame of the newest checksum, 0 for none
+	remoteSums map[int]uint64         // remote checksums we can't check yet
+	Desync     int                    // first frame that didn't match, -1 if none
+
+	welcome   []byte // host only: resent if the guest didn't get it
+	idle      int    // ticks since the last packet
+	Rollbacks int
+	Stalls    int
+}
+
+func newSession(conn PacketConn, local, delay int) *Session {
+	return &Session{
+		conn:       conn,
+		Local:      local,
+		Delay:      delay,
+		localNext:  delay,
+		remoteNext: delay, // The first Delay frames have no input from anyone
+		remoteAck:  delay,
+		rollbackTo: -1,
+		sumNext:    checksumEvery,
+		remoteSums: make(map[int]uint64),
+		Desync:     -1,
+	}
+}
+
+func (s *Session) remote() int { return 1 - s.Local }
+
+// frameInputs returns both players' inputs for frame f, predicting the remote
+// input if it hasn't arrived. A prediction repeats their last known input
+// without its one-shot presses.
+func (s *Session) frameInputs(f int) [maxPlayers]Input {
+	r := s.remote()
+	var remote netInput
+	if f < s.remoteNext {
+		remote = s.inputs[r][f%rollbackWindow]
+	} else if s.remoteNext > 0 {
+		remote = s.inputs[r][(s.remoteNext-1)%rollbackWindow]
+		remote.Buttons &^= btnSwap | btnBomb
+	}
+	s.used[f%rollbackWindow] = remote
+
+	var in [maxPlayers]Input
+	in[s.Local] = s.inputs[s.Local][f%rollbackWindow].Input()
+	in[r] = remote.Input()
+	return in
+}
+
+// receive handles every waiting packet. It returns a message if the session
+// should end.
+func (s *Session) receive() string {
+	for {
+		data, ok := s.conn.Recv()
+		if !ok {
+			return ""
+		}
+		if len(data) == 0 {
+			continue
+		}
+		s.idle = 0
+		switch data[0] {
+		case packetQuit:
+			return "Partner left the game"
+		case packetHello:
+			if s.welcome != nil {
+				s.conn.Send(s.welcome)
+			}
+		case packetInput:
+			var p inputPacket
+			if p.Unmarshal(data) != nil {
+				continue
+			}
+			s.handleInputs(&p)
+		}
+	}
+}
+
+func (s *Session) handleInputs(p *inputPacket) {
+	if ack := int(p.Ack); ack > s.remoteAck {
+		s.remoteAck = ack
+	}
+	r := s.remote()
+	for i, in := range p.Inputs {
+		f := int(p.Start) + i
+		if f != s.remoteNext || f >= s.Frame+rollbackWindow-maxPrediction {
+			continue // Already have it, or too far ahead to keep
+		}
+		s.inputs[r][f%rollbackWindow] = in
+		s.remoteNext++
+		if f < s.Frame && s.used[f%rollbackWindow] != in && (s.rollbackTo < 0 || f < s.rollbackTo) {
+			s.rollbackTo = f
+		}
+	}
+	if p.SumFrame > 0 {
+		s.remoteSums[int(p.SumFrame)] = p.Sum
+	}
+}
+
+// confirmed is the first frame whose inputs aren't all known yet.
+func (s *Session) confirmed() int {
+	return min(s.localNext, s.remoteNext)
+}
+
+// updateChecksums hashes newly confirmed snapshots and compares any checksums
+// the remote sent for the same frames.
+func (s *Session) updateChecksums() {
+	for s.sumNext < s.Frame && s.sumNext <= s.confirmed() {
+		f := s.sumNext
+		s.sums[f%rollbackWindow] = s.states[f%rollbackWindow].checksum()
+		s.lastSum = f
+		s.sumNext += checksumEvery
+	}
+	for f, sum := range s.remoteSums {
+		if f > s.lastSum {
+			continue // Not computed here yet
+		}
+		if f > s.lastSum-rollbackWindow && sum != s.sums[f%rollbackWindow] && s.Desync < 0 {
+			s.Desync = f
+		}
+		delete(s.remoteSums, f)
+	}
+}
+
+func (s *Session) send() {
+	p := inputPacket{Ack: uint32(s.remoteNext), Start: uint32(s.remoteAck)}
+	for f := s.remoteAck; f < s.localNext && len(p.Inputs) < maxInputsPerPkt; f++ {
+		p.Inputs = append(p.Inputs, s.inputs[s.Local][f%rollbackWindow])
+	}
+	if s.lastSum > 0 {
+		p.SumFrame = uint32(s.lastSum)
+		p.Sum = s.sums[s.lastSum%rollbackWindow]
+	}
+	s.conn.Send(p.Marshal())
+}
+
+// close tells the remote we're leaving and shuts the connection.
+func (s *Session) close() {
+	s.conn.Send([]byte{packetQuit})
+	s.conn.Close()
+}
+
+// --- Netplay Hooks ---
+
+// netSettings are the host's choices the guest needs to run the same game.
+type netSettings struct {
+	Seed            int64      `json:"seed"`
+	Difficulty      Difficulty `json:"difficulty"`
+	Mode            int        `json:"mode"`
+	TwinStick       bool       `json:"twin_stick"`
+	Adaptive        bool       `json:"adaptive"`
+	SharedContinues bool       `json:"shared_continues"`
+	Delay           int        `json:"delay"`
+}
+
+// startNetplay begins an online co-op run with both players in from frame 0.
+func (g *Game) startNetplay(conn PacketConn, local int, ns netSettings) {
+	g.seed = ns.Seed
+	d := ns.Difficulty
+	g.difficulty = &d
+	g.modeIdx = ns.Mode
+	g.mode = gameModes[ns.Mode]
+	g.twinStick = ns.TwinStick
+	g.adaptive = ns.Adaptive
+	g.sharedContinues = ns.SharedContinues
+	g.resetRun()
+	g.joinPlayer()
+	g.net = newSession(conn, local, ns.Delay)
+	g.netStatus = ""
+	g.gameState = "playing"
+}
+
+// endNetplay closes the session. A finished run goes to the end card, anything
+// else back to the lobby with msg.
+func (g *Game) endNetplay(msg string) {
+	g.net.close()
+	g.net = nil
+	g.netStatus = msg
+	if g.runOver {
+		g.finishRun()
+		return
+	}
+	g.gameState = "lobby"
+}
+
+// updateNetplay runs one tick of an online run: read packets, add our input,
+// rewind and resimulate if a prediction was wrong, then simulate the next
+// frame unless we're too far ahead of the other player.
+func (g *Game) updateNetplay() {
+	s := g.net
+	if msg := s.receive(); msg != "" {
+		g.endNetplay(msg)
+		return
+	}
+	s.idle++
+	if s.idle > netTimeout {
+		g.endNetplay("Connection lost")
+		return
+	}
+
+	if s.rollbackTo >= 0 && s.rollbackTo < s.Frame {
+		g.loadState(&s.states[s.rollbackTo%rollbackWindow])
+		g.resimulating = true
+		for f := s.rollbackTo; f < s.Frame; f++ {
+			s.states[f%rollbackWindow] = g.saveState()
+			g.step(s.frameInputs(f))
+		}
+		g.resimulating = false
+		s.Rollbacks++
+	}
+	s.rollbackTo = -1
+
+	if s.Frame-s.remoteNext >= maxPrediction {
+		// Wait for the other player to catch up
+		s.Stalls++
+	} else {
+		local := g.takeInputs()[0]
+		s.inputs[s.Local][s.localNext%rollbackWindow] = encodeInput(local)
+		s.localNext++
+
+		s.states[s.Frame%rollbackWindow] = g.saveState()
+		in := s.frameInputs(s.Frame)
+		g.lastInputs = in
+		g.step(in)
+		s.Frame++
+	}
+	s.updateChecksums()
+	s.send()
+
+	if s.Desync >= 0 {
+		g.endNetplay(fmt.Sprintf("Desync detected at frame %d", s.Desync))
+		return
+	}
+	// Only end once every input up to the end is known, so a rollback can't
+	// bring the run back
+	if g.runOver && s.confirmed() >= s.Frame {
+		g.endNetplay("")
+	}
+}
+
+// drawNetHUD shows the connection state during an online run.
+func (g *Game) drawNetHUD(screen *ebiten.Image) {
+	s := g.net
+	if s == nil {
+		return
+	}
+	line := fmt.Sprintf("P%d ONLINE  delay %d  ahead %d  rollbacks %d", s.Local+1, s.Delay, s.Frame-s.remoteNext, s.Rollbacks)
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(float64(screenWidth)/2-float64(len(line))*4, float64(screenHeight)-44)
+	op.ColorScale.ScaleWithColor(color.RGBA{160, 160, 160, 255})
+	text.Draw(screen, line, fontFace, op)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memConn is one end of an in-memory PacketConn pair. Like UDP it never
+// blocks: a full queue drops the packet.
+type memConn struct {
+	in, out chan []byte
+	closed  *atomic.Bool
+}
+
+func memPipe() (*memConn, *memConn) {
+	a, b := make(chan []byte, 256), make(chan []byte, 256)
+	closed := &atomic.Bool{}
+	return &memConn{in: a, out: b, closed: closed}, &memConn{in: b, out: a, closed: closed}
+}
+
+func (c *memConn) Send(data []byte) error {
+	if c.closed.Load() {
+		return nil
+	}
+	select {
+	case c.out <- append([]byte(nil), data...):
+	default:
+	}
+	return nil
+}
+
+func (c *memConn) Recv() ([]byte, bool) {
+	select {
+	case data := <-c.in:
+		return data, true
+	default:
+		return nil, false
+	}
+}
+
+func (c *memConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// newNetPair starts the host and guest of an online run over the two conns.
+func newNetPair(host, guest PacketConn) (a, b *Game) {
+	ns := netSettings{Seed: 7, Difficulty: *difficulties[defaultDifficulty], Delay: 2}
+	a, b = &Game{camera: NewCamera()}, &Game{camera: NewCamera()}
+	a.startNetplay(host, 0, ns)
+	b.startNetplay(guest, 1, ns)
+	for _, g := range []*Game{a, b} {
+		for _, p := range g.players.Items {
+			p.Lives = 1000 // Keep the run going
+		}
+	}
+	return a, b
+}
+
+// scriptedInput is what a player holds on their frame f, changing every few
+// frames so predictions keep going wrong.
+func scriptedInput(player, f int) Input {
+	k := f/5 + player*3
+	return Input{Left: k%4 == 0, Right: k%4 == 2, Up: k%3 == 1, Fire: k%2 == 0}
+}
+
+// runNet ticks both games until done says stop or either leaves the run.
+func runNet(t *testing.T, a, b *Game, done func() bool) {
+	deadline := time.Now().Add(20 * time.Second)
+	for !done() {
+		if a.net == nil || b.net == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out at frames %d and %d", a.net.Frame, b.net.Frame)
+		}
+		for _, g := range []*Game{a, b} {
+			g.sampled[0] = scriptedInput(g.net.Local, g.net.localNext)
+			g.updateNetplay()
+			if g.net == nil {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRollbackStaysInSync(t *testing.T) {
+	const frames = 600
+	c1, c2 := memPipe()
+	a, b := newNetPair(
+		NewLossyConn(c1, 0.1, 3*time.Millisecond, 4*time.Millisecond, 1),
+		NewLossyConn(c2, 0.1, 3*time.Millisecond, 4*time.Millisecond, 2),
+	)
+	runNet(t, a, b, func() bool { return a.net.lastSum >= frames && b.net.lastSum >= frames })
+	if a.net == nil || b.net == nil {
+		t.Fatalf("run ended early: %q / %q", a.netStatus, b.netStatus)
+	}
+	if a.net.Desync >= 0 || b.net.Desync >= 0 {
+		t.Fatalf("desync at frames %d and %d", a.net.Desync, b.net.Desync)
+	}
+	f := min(a.net.lastSum, b.net.lastSum)
+	if sa, sb := a.net.sums[f%rollbackWindow], b.net.sums[f%rollbackWindow]; sa != sb {
+		t.Errorf("frame %d checksums differ: %x and %x", f, sa, sb)
+	}
+	if a.net.Rollbacks == 0 && b.net.Rollbacks == 0 {
+		t.Error("no rollbacks, so the test didn't exercise prediction")
+	}
+}
+
+func TestRollbackDetectsDesync(t *testing.T) {
+	c1, c2 := memPipe()
+	a, b := newNetPair(
+		NewLossyConn(c1, 0.1, 3*time.Millisecond, 0, 1),
+		NewLossyConn(c2, 0.1, 3*time.Millisecond, 0, 2),
+	)
+	b.difficulty.PlayerSpeed += 1 // The guest's ships drift from the host's
+	runNet(t, a, b, func() bool { return false })
+	if !strings.HasPrefix(a.netStatus, "Desync detected") && !strings.HasPrefix(b.netStatus, "Desync detected") {
+		t.Errorf("run ended with %q / %q, want a desync", a.netStatus, b.netStatus)
+	}
+}
+
+// lobbyGame is a game sitting in the online lobby with a simulated bad
+// connection picked.
+func lobbyGame(addr string) *Game {
+	return &Game{
+		camera:        NewCamera(),
+		mode:          gameModes[0],
+		difficultyIdx: defaultDifficulty,
+		usernameInput: "test",
+		gameState:     "lobby",
+		lobby:         Lobby{Addr: addr, Delay: defaultNetDelay, SimIdx: 1},
+	}
+}
+
+func TestLobbyOverUDP(t *testing.T) {
+	const frames = 300
+	host := lobbyGame("127.0.0.1:0")
+	host.hostGame()
+	if host.lobby.conn == nil {
+		t.Fatal(host.netStatus)
+	}
+	lossy, ok := host.lobby.conn.(*LossyConn)
+	if !ok {
+		t.Fatalf("host conn is %T, want the lobby's LossyConn", host.lobby.conn)
+	}
+	port := lossy.PacketConn.(*UDPPeer).conn.LocalAddr().(*net.UDPAddr).Port
+	guest := lobbyGame(fmt.Sprintf("127.0.0.1:%d", port))
+	guest.joinGame()
+	if guest.lobby.conn == nil {
+		t.Fatal(guest.netStatus)
+	}
+	t.Cleanup(func() {
+		for _, g := range []*Game{host, guest} {
+			if g.net != nil {
+				g.net.close()
+			} else {
+				g.cancelLobby("")
+			}
+		}
+	})
+
+	// Poll once a tick, as the lobby screen does, so the join timeout means
+	// what it does in the game
+	for host.net == nil || guest.net == nil {
+		for _, g := range []*Game{host, guest} {
+			if g.net != nil {
+				continue
+			}
+			if g.lobby.conn == nil {
+				t.Fatalf("no handshake: %q / %q", host.netStatus, guest.netStatus)
+			}
+			g.pollLobby()
+		}
+		time.Sleep(time.Second / defaultSimRate)
+	}
+	if guest.seed != host.seed {
+		t.Fatalf("guest got seed %d, host plays %d", guest.seed, host.seed)
+	}
+	for _, g := range []*Game{host, guest} {
+		for _, p := range g.players.Items {
+			p.Lives = 1000 // Keep the run going
+		}
+	}
+
+	runNet(t, host, guest, func() bool { return host.net.lastSum >= frames && guest.net.lastSum >= frames })
+	if host.net == nil || guest.net == nil {
+		t.Fatalf("run ended early: %q / %q", host.netStatus, guest.netStatus)
+	}
+	if host.net.Desync >= 0 || guest.net.Desync >= 0 {
+		t.Fatalf("desync at frames %d and %d", host.net.Desync, guest.net.Desync)
+	}
+	f := min(host.net.lastSum, guest.net.lastSum)
+	if sa, sb := host.net.sums[f%rollbackWindow], guest.net.sums[f%rollbackWindow]; sa != sb {
+		t.Errorf("frame %d checksums differ: %x and %x", f, sa, sb)
+	}
+}
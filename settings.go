@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// --- Settings Toggles ---
+
+// settingsToggle is an option on the settings card that changes when clicked.
+type settingsToggle struct {
+	Label func(g *Game) string
+	Click func(g *Game)
+}
+
+// settingsToggles sit in two columns under the aim toggle.
+var settingsToggles = []settingsToggle{
+	{
+		Label: func(g *Game) string { return "Adaptive: " + onOff(g.adaptive) },
+		Click: func(g *Game) { g.adaptive = !g.adaptive },
+	},
+	{
+		Label: func(g *Game) string {
+			if g.sharedContinues {
+				return "Continues: Shared"
+			}
+			return "Continues: Separate"
+		},
+		Click: func(g *Game) { g.sharedContinues = !g.sharedContinues },
+	},
+	{
+		Label: func(g *Game) string {
+			if g.specServer != nil {
+				return fmt.Sprintf("Spectators: On (%d)", g.specServer.Count())
+			}
+			return "Spectators: Off"
+		},
+		Click: (*Game).toggleSpectators,
+	},
+	{
+		Label: func(g *Game) string { return "Particles: " + particleLevels[g.particleLevel].Label },
+		Click: func(g *Game) {
+			g.particleLevel = (g.particleLevel + 1) % len(particleLevels)
+			g.fx.SetLevel(g.particleLevel)
+		},
+	},
+	{
+		Label: func(g *Game) string {
+			label := fmt.Sprintf("Sim: %d Hz", simRates[g.simRateIdx])
+			if simRates[g.simRateIdx] != defaultSimRate {
+				label += " (practice)"
+			}
+			return label
+		},
+		Click: func(g *Game) { g.simRateIdx = (g.simRateIdx + 1) % len(simRates) },
+	},
+	{
+		Label: func(g *Game) string {
+			if g.proceduralBg {
+				return "Background: Procedural"
+			}
+			return "Background: Classic"
+		},
+		Click: func(g *Game) {
+			g.proceduralBg = !g.proceduralBg
+			g.useStage(g.mode.Name())
+		},
+	},
+	{
+		Label: func(g *Game) string { return "Effects..." },
+		Click: func(g *Game) { g.gameState = "effects" },
+	},
+	{
+		Label: func(g *Game) string { return "Camera..." },
+		Click: func(g *Game) { g.gameState = "camera" },
+	},
+	{
+		Label: func(g *Game) string { return "Audio..." },
+		Click: func(g *Game) { g.gameState = "audio" },
+	},
+}
+
+func onOff(on bool) string {
+	if on {
+		return "On"
+	}
+	return "Off"
+}
+
+// settingsToggleRect returns the rectangle of toggle i on a card at cardY.
+func settingsToggleRect(cardY float64, i int) (x, y, w, h float64) {
+	w, h = 188, 32
+	return float64(screenWidth)/2 - 192 + float64(i%2)*196, cardY + 200 + float64(i/2)*36, w, h
+}
+
+// settingsToggleAt returns the toggle under (x, y), or -1.
+func settingsToggleAt(cardY, x, y float64) int {
+	for i := range settingsToggles {
+		tx, ty, tw, th := settingsToggleRect(cardY, i)
+		if x >= tx && x <= tx+tw && y >= ty && y <= ty+th {
+			return i
+		}
+	}
+	return -1
+}
+
+// --- Settings Pages ---
+
+// settingsRow is an option on a settings page: a button, a slider, or a
+// button with a slider beside it.
+type settingsRow struct {
+	Label func(g *Game) string
+	Click func(g *Game) // nil if the label can't be clicked
+	Value func(g *Game) float64
+	Set   func(g *Game, v float64) // nil for no slider
+}
+
+// settingsPage is a card of extra settings opened from the main settings
+// card. Its game state is its key in settingsPages.
+type settingsPage struct {
+	Title string
+	Rows  []settingsRow
+	Close func(g *Game) // called on leaving the page, if set
+}
+
+var settingsPages = map[string]*settingsPage{
+	"effects": effectsPage(),
+	"audio":   audioPage(),
+	"camera": {Title: "Camera", Rows: []settingsRow{
+		{
+			Label: func(g *Game) string { return "Shake: " + onOff(g.cameraOpts.Shake) },
+			Click: func(g *Game) { g.cameraOpts.Shake = !g.cameraOpts.Shake },
+			Value: func(g *Game) float64 { return g.cameraOpts.Amount },
+			Set:   func(g *Game, v float64) { g.cameraOpts.Amount = v },
+		},
+		{
+			Label: func(g *Game) string { return "Hit-Stop: " + onOff(g.cameraOpts.HitStop) },
+			Click: func(g *Game) { g.cameraOpts.HitStop = !g.cameraOpts.HitStop },
+		},
+		{
+			Label: func(g *Game) string {
+				return fmt.Sprintf("Zoom: %d%%", int(zoomLevels[g.cameraOpts.Zoom]*100))
+			},
+			Click: func(g *Game) { g.cameraOpts.Zoom = (g.cameraOpts.Zoom + 1) % len(zoomLevels) },
+		},
+	}},
+}
+
+func effectsPage() *settingsPage {
+	page := &settingsPage{Title: "Effects"}
+	for i := range numEffects {
+		page.Rows = append(page.Rows, settingsRow{
+			Label: func(g *Game) string {
+				if g.post == nil {
+					return effectInfos[i].Name + ": N/A"
+				}
+				return effectInfos[i].Name + ": " + onOff(g.effects[i].On)
+			},
+			Click: func(g *Game) { g.effects[i].On = !g.effects[i].On },
+			Value: func(g *Game) float64 { return g.effects[i].Intensity },
+			Set:   func(g *Game, v float64) { g.effects[i].Intensity = v },
+		})
+	}
+	return page
+}
+
+// audioPage has the volume sliders, saved when the page closes.
+func audioPage() *settingsPage {
+	volume := func(label string, part func(s *AudioSettings) *float64) settingsRow {
+		return settingsRow{
+			Label: func(g *Game) string { return label },
+			Value: func(g *Game) float64 {
+				if g.audio == nil {
+					return 0
+				}
+				return *part(&g.audio.Settings)
+			},
+			Set: func(g *Game, v float64) {
+				if g.audio != nil {
+					*part(&g.audio.Settings) = v
+				}
+			},
+		}
+	}
+	return &settingsPage{
+		Title: "Audio",
+		Rows: []settingsRow{
+			volume("Master", func(s *AudioSettings) *float64 { return &s.Master }),
+			volume("Music", func(s *AudioSettings) *float64 { return &s.Music }),
+			volume("Sound Effects", func(s *AudioSettings) *float64 { return &s.SFX }),
+			{
+				Label: func(g *Game) string {
+					if g.audio == nil {
+						return "Muted: N/A"
+					}
+					return "Muted: " + onOff(g.audio.Settings.Muted) + " (M)"
+				},
+				Click: func(g *Game) {
+					if g.audio != nil {
+						g.audio.Settings.Muted = !g.audio.Settings.Muted
+					}
+				},
+			},
+		},
+		Close: func(g *Game) {
+			if g.audio != nil {
+				saveAudioSettings(g.audio.Settings)
+			}
+		},
+	}
+}
+
+func (p *settingsPage) cardRect() (x, y, w, h float64) {
+	w, h = 400, 160+float64(len(p.Rows))*36
+	return float64(screenWidth)/2 - w/2, float64(screenHeight)/2 - h/2, w, h
+}
+
+// rowRects returns the button and slider of row i. A row without a slider
+// has a full-width button.
+func (p *settingsPage) rowRects(i int) (button, slider [4]float64) {
+	cx, cy, cw, _ := p.cardRect()
+	y := cy + 80 + float64(i)*36
+	if p.Rows[i].Set == nil {
+		return [4]float64{cx + 8, y, cw - 16, 32}, [4]float64{}
+	}
+	return [4]float64{cx + 8, y, 188, 32}, [4]float64{cx + 204, y, 188, 32}
+}
+
+func (p *settingsPage) backRect() (x, y, w, h float64) {
+	cx, cy, cw, ch := p.cardRect()
+	return cx + cw/2 - 60, cy + ch - 64, 120, 40
+}
+
+func (g *Game) updateSettingsPage(p *settingsPage) {
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		g.dragSlider = 0
+	}
+	for i, row := range p.Rows {
+		button, slider := p.rowRects(i)
+		if row.Click != nil && clickedIn(button[0], button[1], button[2], button[3]) {
+			row.Click(g)
+		}
+		if row.Set == nil {
+			continue
+		}
+		if clickedIn(slider[0], slider[1], slider[2], slider[3]) {
+			g.dragSlider = i + 1
+		}
+		if g.dragSlider == i+1 {
+			row.Set(g, sliderAt(slider[0], slider[2]))
+		}
+	}
+	if clickedIn(p.backRect()) || inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.dragSlider = 0
+		g.gameState = "settings"
+		if p.Close != nil {
+			p.Close(g)
+		}
+	}
+}
+
+func (g *Game) drawSettingsPage(screen *ebiten.Image, p *settingsPage) {
+	screen.Fill(color.RGBA{0, 0, 0, 255})
+	x, y, w, h := p.cardRect()
+	card := Card{
+		X: x, Y: y, W: w, H: h,
+		BgColor: color.RGBA{30, 30, 40, 220},
+		DrawContent: func(screen *ebiten.Image, c *Card) {
+			titleOp := &text.DrawOptions{}
+			titleOp.GeoM.Translate(c.X+c.W/2-float64(len(p.Title))*4, c.Y+36)
+			text.Draw(screen, p.Title, fontFace, titleOp)
+
+			for i, row := range p.Rows {
+				button, slider := p.rowRects(i)
+				if row.Click != nil {
+					drawButton(screen, button[0], button[1], button[2], button[3], row.Label(g))
+				} else {
+					labelOp := &text.DrawOptions{}
+					labelOp.GeoM.Translate(button[0]+8, button[1]+6)
+					text.Draw(screen, row.Label(g), fontFace, labelOp)
+				}
+				if row.Set != nil {
+					drawSlider(screen, slider[0], slider[1], slider[2], slider[3], row.Value(g))
+				}
+			}
+			bx, by, bw, bh := p.backRect()
+			drawButton(screen, bx, by, bw, bh, "Back")
+		},
+	}
+	card.Draw(screen)
+}
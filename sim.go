@@ -0,0 +1,297 @@
+package main
+
+import (
+	"image/color"
+	"math"
+)
+
+// --- Simulation ---
+
+// step advances a run by one tick. It reads only the given inputs and the
+// run's own state, so the same inputs always play out the same way.
+func (g *Game) step(inputs [maxPlayers]Input) {
+	if g.runOver {
+		return
+	}
+	for _, sys := range simSystems {
+		sys.Run(g, inputs)
+	}
+}
+
+// clockSystem advances the run's timers and lets latecomers join.
+func (g *Game) clockSystem(inputs [maxPlayers]Input) {
+	g.elapsedFrames++ // Track time
+	g.stats.Frames++
+	g.stats.Rate = g.simRate()
+	if g.directorOn() {
+		g.director.Update(g.stats)
+	}
+	g.mode.OnTick(g)
+	if g.bombFrames > 0 {
+		g.bombFrames--
+	}
+
+	if inputs[1].Join {
+		g.joinPlayer()
+	}
+	g.updateRespawns()
+}
+
+// playerSystem moves the players and runs their weapons and bombs.
+func (g *Game) playerSystem(inputs [maxPlayers]Input) {
+	speed := g.difficulty.PlayerSpeed * g.tickScale()
+	for i, p := range g.players.Items {
+		if p.Out {
+			continue
+		}
+		in := inputs[i]
+
+		// Player movement
+		if in.Up {
+			p.Y -= speed
+		}
+		if in.Down {
+			p.Y += speed
+		}
+		if in.Left {
+			p.X -= speed
+		}
+		if in.Right {
+			p.X += speed
+		}
+		// Clamp to screen
+		p.X = max(0, min(float64(screenWidth)-p.Size, p.X))
+		p.Y = max(0, min(float64(screenHeight)-p.Size, p.Y))
+
+		// Tick down timed pickup effects
+		for k := range p.Effects {
+			if p.Effects[k] > 0 {
+				p.Effects[k]--
+			}
+		}
+		if p.Invuln > 0 {
+			p.Invuln--
+		}
+
+		// Weapon swap, hold-to-fire and charge shots
+		g.updateTrigger(p, in)
+		if in.Bomb && g.mode.AllowBombs() {
+			g.detonateBomb(p)
+		}
+	}
+}
+
+// spawnSystem tightens the spawn interval over time and brings in waves.
+func (g *Game) spawnSystem([maxPlayers]Input) {
+	// Gradually decrease spawnInterval, but not below the difficulty's minimum
+	d := g.difficulty
+	if g.elapsedFrames%g.ticks(d.SpawnRampFrames) == 0 && g.spawnInterval > d.SpawnMin {
+		g.spawnInterval -= d.SpawnStep
+		if g.spawnInterval < d.SpawnMin {
+			g.spawnInterval = d.SpawnMin
+		}
+	}
+
+	g.spawnCounter++
+	if g.spawnCounter >= g.ticks(g.spawnIntervalNow()) {
+		g.spawnCounter = 0
+		ramp := d.SpawnStart - g.spawnInterval
+		numEnemies := 1 + ramp/20
+		for i := 0; i < numEnemies; i++ {
+			kind := randomEnemyKind(g.spawnRng, ramp)
+			enemy := g.enemies.Add(NewEnemy(g.spawnRng, kind, float64(32+g.spawnRng.Intn(screenWidth-64)), float64(screenHeight), d))
+			enemy.Cooldown = g.ticks(g.scaleFireCooldown(enemy.Cooldown))
+			enemy.ID = g.nextEnemyID
+			enemy.Phase = enemy.ID * 7 // So a wave doesn't animate in step
+			g.nextEnemyID++
+		}
+	}
+}
+
+// enemyAISystem has everything with a Shooter fire at the nearest living
+// player.
+func (g *Game) enemyAISystem([maxPlayers]Input) {
+	for _, s := range g.stores() {
+		for i := range s.Len() {
+			// Gone ones were killed earlier this tick, e.g. by a bomb
+			if e := s.At(i).Parts(); e.Shooter != nil && !e.gone() {
+				g.shoot(e.Transform, e.Shooter)
+			}
+		}
+	}
+}
+
+// shoot counts down a Shooter at t and fires when it's ready.
+func (g *Game) shoot(t *Transform, sh *Shooter) {
+	d := g.difficulty
+	ex, ey := t.Center()
+	target := g.nearestPlayer(ex, ey)
+	if target == nil {
+		return
+	}
+	sh.Cooldown--
+	if sh.Cooldown > 0 {
+		return
+	}
+	tx, ty := target.Center()
+	dx, dy := tx-ex, ty-ey
+	dist := dx*dx + dy*dy
+	if dist == 0 {
+		return
+	}
+	length := math.Sqrt(dist)
+	speed := d.EnemyBulletSpeed
+	g.enemyBullets.Add(EnemyBullet{
+		Transform: Transform{X: ex - 3, Y: ey - 3, Size: 6},
+		Velocity:  Velocity{SpeedX: dx / length * speed, SpeedY: dy / length * speed},
+		Hitbox:    Hitbox{Shape: ShapeCircle},
+		Visual:    Visual{Sprite: "enemy_bullet", Tint: enemyBulletColor, Layer: layerEnemyShots, Rotate: true, Glow: true},
+	})
+	g.playSound("enemy_shoot")
+	sh.Cooldown = g.ticks(g.scaleFireCooldown(d.FireCooldownMin + g.rng.Intn(d.FireCooldownRange)))
+}
+
+// homingSystem turns homing bullets toward their targets.
+func (g *Game) homingSystem([maxPlayers]Input) {
+	for _, b := range g.bullets.Items {
+		if b.Homing {
+			g.steerHoming(b)
+		}
+	}
+}
+
+// bulletHitSystem damages enemies hit by player bullets.
+func (g *Game) bulletHitSystem([maxPlayers]Input) {
+	g.enemyGrid.BuildFrom(&g.enemies)
+	for _, b := range g.bullets.Items {
+		bc := b.Parts().Collider()
+		for _, i := range g.enemyGrid.Query(bc) {
+			e := g.enemies.Items[i]
+			if e.Gone || b.HasHit(e.ID) || !Overlaps(bc, e.Parts().Collider()) {
+				continue
+			}
+			b.AddHit(e.ID)
+			g.sparkImpact(b)
+			g.playSound("hit")
+			if e.Hurt(b.Damage, g.ticks(hitFlash)) {
+				e.Gone = true
+				g.killEnemy(e, g.players.Items[b.Owner])
+			}
+			if b.Pierce > 0 {
+				b.Pierce--
+				continue
+			}
+			b.Gone = true
+			break
+		}
+	}
+	g.bullets.Sweep()
+	g.enemies.Sweep()
+}
+
+// pickupSystem hands pickups to players who touch them.
+func (g *Game) pickupSystem([maxPlayers]Input) {
+	g.updatePickups()
+}
+
+// scoringSystem runs the combo timer and bomb milestones.
+func (g *Game) scoringSystem([maxPlayers]Input) {
+	g.updateCombo()
+	g.checkBombMilestone()
+}
+
+// playerHitSystem damages players hit by enemy bullets or enemies and
+// scores grazes. A run ending here leaves everyone out, so the rest of the
+// loop does nothing.
+func (g *Game) playerHitSystem([maxPlayers]Input) {
+	g.enemyGrid.BuildFrom(&g.enemies)
+	g.enemyBulletGrid.BuildFrom(&g.enemyBullets)
+	for _, p := range g.players.Items {
+		if p.Out || p.Invuln > 0 {
+			continue
+		}
+		pc := p.Parts().Collider()
+		playerHit := false
+		for _, i := range g.enemyBulletGrid.Query(p.grazeCollider()) {
+			eb := g.enemyBullets.Items[i]
+			if eb.Gone {
+				continue
+			}
+			if !playerHit && Overlaps(eb.Parts().Collider(), pc) {
+				playerHit = true
+				eb.Gone = true
+				continue
+			}
+			g.checkGraze(p, eb)
+		}
+		if playerHit {
+			g.damagePlayer(p)
+			continue
+		}
+
+		// Player vs Enemy collision
+		for _, i := range g.enemyGrid.Query(pc) {
+			if Overlaps(pc, g.enemies.Items[i].Parts().Collider()) {
+				g.damagePlayer(p)
+				break
+			}
+		}
+	}
+	g.enemyBullets.Sweep()
+}
+
+// rulesSystem ends the run when the mode's clock runs out.
+func (g *Game) rulesSystem([maxPlayers]Input) {
+	if !g.runOver && g.mode.TimeUp(g) {
+		g.endRun("Time's Up!")
+	}
+}
+
+// killEnemy scores a destroyed enemy for p and rolls its drop table.
+func (g *Game) killEnemy(e *Enemy, p *Player) {
+	g.registerKill()
+	g.explodeEnemy(e)
+	g.shakeCamera(0.12 * e.Size / 32)
+	g.playSound("explosion")
+	g.hitStop(hitStopKill)
+	g.addScore(p, g.mode.KillPoints(enemyKinds[e.Kind].Points), e.X+e.Size/2, e.Y, color.RGBA{255, 255, 255, 255})
+	g.stats.Kills++
+	p.Stats.Kills++
+	if kind, ok := dropTables[e.Kind].Roll(g.rng, g.dropChanceScale()); ok {
+		g.pickups.Add(NewPickup(g.rng, kind, e.X+e.Size/2, e.Y+e.Size/2))
+	}
+}
+
+// damagePlayer applies one hit to p, consuming a shield or a life first. A
+// player out of lives uses a continue if there is one. It reports whether the
+// hit ended the run.
+func (g *Game) damagePlayer(p *Player) bool {
+	g.stats.HitsTaken++
+	p.Stats.HitsTaken++
+	g.breakCombo()
+	if p.Effects[PickupShield] > 0 {
+		p.Effects[PickupShield] = 0
+		p.Invuln = g.ticks(60)
+		return false
+	}
+	p.Lives--
+	g.explodePlayer(p)
+	g.flashDamage()
+	g.shakeCamera(0.5)
+	g.playSound("death")
+	if p.Lives > 0 {
+		p.Invuln = g.ticks(120)
+		return false
+	}
+	p.Out = true
+	p.Effects = [numPickupKinds]int{}
+	p.Charge = 0
+	if g.useContinue(p) {
+		p.Respawn = g.ticks(respawnDelay)
+	}
+	if g.anyPlayerIn() {
+		return false
+	}
+	g.endRun("Game Over")
+	return true
+}
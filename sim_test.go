@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+// newTestGame starts a seeded Endless run with no window, sound or effects.
+func newTestGame(seed int64) *Game {
+	g := &Game{
+		difficulty: difficulties[defaultDifficulty],
+		mode:       gameModes[0],
+		camera:     NewCamera(),
+		seed:       seed,
+	}
+	g.resetRun()
+	return g
+}
+
+// warmUp plays n ticks holding fire, so the pools and slices have grown to
+// what the run needs.
+func warmUp(g *Game, n int) [maxPlayers]Input {
+	var in [maxPlayers]Input
+	in[0].Fire = true
+	for i := range n {
+		in[0].Left = i/60%2 == 0
+		in[0].Right = !in[0].Left
+		g.step(in)
+	}
+	return in
+}
+
+func TestStepDoesNotAllocate(t *testing.T) {
+	g := newTestGame(1)
+	g.players.Items[0].Lives = 1000 // Keep the run going
+	in := warmUp(g, 3000)
+	if g.runOver || g.enemies.Len() == 0 {
+		t.Fatalf("warm-up left nothing to simulate: over %v, %d enemies", g.runOver, g.enemies.Len())
+	}
+	if allocs := testing.AllocsPerRun(100, func() { g.step(in) }); allocs != 0 {
+		t.Errorf("step allocated %v times per tick, want 0", allocs)
+	}
+}
+
+func TestPiercingBulletHitsEachEnemyOnce(t *testing.T) {
+	g := newTestGame(1)
+	a := g.enemies.Add(Enemy{ID: 1, Transform: Transform{X: 100, Y: 100, Size: 32}, Health: Health{HP: 100}})
+	b := g.enemies.Add(Enemy{ID: 2, Transform: Transform{X: 110, Y: 100, Size: 32}, Health: Health{HP: 100}})
+	g.bullets.Add(Bullet{Transform: Transform{X: 120, Y: 110, Size: 6}, Damage: 1, Pierce: 5})
+	// The bullet sits still over both enemies for several ticks
+	for range 5 {
+		g.bulletHitSystem([maxPlayers]Input{})
+	}
+	if g.bullets.Len() != 1 {
+		t.Fatal("bullet spent with pierce left")
+	}
+	if a.HP != 99 || b.HP != 99 {
+		t.Errorf("HP %d and %d, want each enemy hit once (99)", a.HP, b.HP)
+	}
+	if got := g.bullets.Items[0].Pierce; got != 3 {
+		t.Errorf("pierce left %d, want 3", got)
+	}
+}
+
+func TestBombedEnemiesDontFire(t *testing.T) {
+	g := newTestGame(1)
+	g.enemies.Add(Enemy{ID: 1, Transform: Transform{X: 300, Y: 200, Size: 32}, Health: Health{HP: 1}, Shooter: Shooter{Cooldown: 1}})
+	var in [maxPlayers]Input
+	in[0].Bomb = true
+	g.step(in)
+	if g.stats.Kills != 1 {
+		t.Fatalf("bomb killed %d enemies, want 1", g.stats.Kills)
+	}
+	if g.enemyBullets.Len() != 0 {
+		t.Errorf("%d enemy bullets right after a bomb", g.enemyBullets.Len())
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// --- Spectator Mode ---
+
+const (
+	specDelay      = 30 // frames of playback delay, to ride out network hiccups
+	specClientBuf  = 120
+	maxSpecFrameSz = 4 << 20
+)
+
+// SpecFrame is one tick of a run as streamed to spectators: everything the
+// playfield and HUD draw. The stream is one JSON frame per line.
+type SpecFrame struct {
+	Frame      int     `json:"frame"`
+	Rate       int     `json:"rate,omitempty"` // the streamer's sim rate
+	Mode       int     `json:"mode"`
+	Score      int     `json:"score"`
+	Combo      int     `json:"combo"`
+	ComboTimer int     `json:"combo_timer"`
+	BombFrames int     `json:"bomb_frames"`
+	BombX      float64 `json:"bomb_x"`
+	BombY      float64 `json:"bomb_y"`
+	Over       bool    `json:"over,omitempty"`
+	Title      string  `json:"title,omitempty"`
+
+	Entities map[string]json.RawMessage `json:"entities"` // each store, by its name in storeNames
+}
+
+// specFrame captures the current run for spectators.
+func (g *Game) specFrame() SpecFrame {
+	f := SpecFrame{
+		Frame:      g.elapsedFrames,
+		Rate:       g.simRate(),
+		Mode:       g.modeIdx,
+		Score:      g.score,
+		Combo:      g.combo,
+		ComboTimer: g.comboTimer,
+		BombFrames: g.bombFrames,
+		BombX:      g.bombX,
+		BombY:      g.bombY,
+		Over:       g.runOver,
+		Title:      g.endTitle,
+		Entities:   make(map[string]json.RawMessage, numStores),
+	}
+	for i, s := range g.stores() {
+		if data, err := s.MarshalJSON(); err == nil {
+			f.Entities[storeNames[i]] = data
+		}
+	}
+	return f
+}
+
+// applySpecFrame shows a streamed frame by loading it into the game, so the
+// normal playfield drawing renders it.
+func (g *Game) applySpecFrame(f *SpecFrame) {
+	if f.Mode >= 0 && f.Mode < len(gameModes) {
+		g.mode = gameModes[f.Mode]
+		g.useStage(g.mode.Name())
+	}
+	g.elapsedFrames = f.Frame
+	if f.Rate > 0 && g.spectator != nil {
+		g.spectator.rate = f.Rate
+	}
+	g.score = f.Score
+	g.combo, g.comboTimer = f.Combo, f.ComboTimer
+	g.bombFrames, g.bombX, g.bombY = f.BombFrames, f.BombX, f.BombY
+	g.runOver, g.endTitle = f.Over, f.Title
+	for i, s := range g.stores() {
+		if s.UnmarshalJSON(f.Entities[storeNames[i]]) != nil {
+			s.Clear() // Missing or garbled
+		}
+	}
+}
+
+// --- Server ---
+
+// SpecServer streams frames over TCP to any number of spectators. Slow
+// spectators miss frames rather than holding up the game.
+type SpecServer struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	clients map[net.Conn]chan []byte
+}
+
+// ListenSpectators starts accepting spectators on addr (e.g. ":7777").
+func ListenSpectators(addr string) (*SpecServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &SpecServer{ln: ln, clients: make(map[net.Conn]chan []byte)}
+	go s.acceptLoop()
+	return s, nil
+}
+
+func (s *SpecServer) acceptLoop() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		out := make(chan []byte, specClientBuf)
+		s.mu.Lock()
+		s.clients[conn] = out
+		s.mu.Unlock()
+		go s.writeLoop(conn, out)
+	}
+}
+
+func (s *SpecServer) writeLoop(conn net.Conn, out chan []byte) {
+	defer s.drop(conn)
+	for data := range out {
+		if _, err := conn.Write(data); err != nil {
+			return
+		}
+	}
+}
+
+func (s *SpecServer) drop(conn net.Conn) {
+	s.mu.Lock()
+	if out, ok := s.clients[conn]; ok {
+		delete(s.clients, conn)
+		close(out)
+	}
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// Count is the number of connected spectators.
+func (s *SpecServer) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Broadcast sends one frame to every spectator.
+func (s *SpecServer) Broadcast(f *SpecFrame) {
+	if s.Count() == 0 {
+		return
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+	s.mu.Lock()
+	for _, out := range s.clients {
+		select {
+		case out <- data:
+		default: // Spectator is behind; skip this frame for them
+		}
+	}
+	s.mu.Unlock()
+}
+
+func (s *SpecServer) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	conns := make([]net.Conn, 0, len(s.clients))
+	for conn := range s.clients {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+	for _, conn := range conns {
+		s.drop(conn)
+	}
+}
+
+// toggleSpectators starts or stops the spectator server on the lobby port.
+func (g *Game) toggleSpectators() {
+	if g.specServer != nil {
+		g.specServer.Close()
+		g.specServer = nil
+		return
+	}
+	srv, err := ListenSpectators(":" + g.lobby.Port())
+	if err != nil {
+		g.netStatus = "Can't stream: " + err.Error()
+		return
+	}
+	g.specServer = srv
+}
+
+// broadcastSpectators streams the current tick if anyone is watching.
+func (g *Game) broadcastSpectators() {
+	if g.specServer == nil || g.specServer.Count() == 0 {
+		return
+	}
+	f := g.specFrame()
+	g.specServer.Broadcast(&f)
+}
+
+// --- Client ---
+
+// SpecClient watches a stream. Frames are played back specDelay frames behind
+// so the picture stays smooth when packets bunch up.
+type SpecClient struct {
+	conn    net.Conn
+	frames  chan *SpecFrame
+	buffer  []*SpecFrame
+	playing bool
+	rate    int // sim rate of the stream, once known
+}
+
+// DialSpectate connects to a game streaming at addr.
+func DialSpectate(addr string) (*SpecClient, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &SpecClient{conn: conn, frames: make(chan *SpecFrame, specClientBuf)}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *SpecClient) readLoop() {
+	defer close(c.frames)
+	sc := bufio.NewScanner(c.conn)
+	sc.Buffer(make([]byte, 64<<10), maxSpecFrameSz)
+	for sc.Scan() {
+		f := &SpecFrame{}
+		if json.Unmarshal(sc.Bytes(), f) != nil {
+			continue
+		}
+		c.frames <- f
+	}
+}
+
+// Next returns the frame to show this tick, nil to keep the current one, and
+// whether the stream is still open.
+func (c *SpecClient) Next() (*SpecFrame, bool) {
+	open := true
+	for drained := false; !drained; {
+		select {
+		case f, ok := <-c.frames:
+			if !ok {
+				open = false
+				drained = true
+				break
+			}
+			c.buffer = append(c.buffer, f)
+		default:
+			drained = true
+		}
+	}
+	if !c.playing && len(c.buffer) >= specDelay {
+		c.playing = true
+	}
+	if !c.playing || len(c.buffer) == 0 {
+		return nil, open || len(c.buffer) > 0
+	}
+	// Catch up if the buffer has grown well past the delay
+	if len(c.buffer) > specDelay*2 {
+		c.buffer = c.buffer[len(c.buffer)-specDelay:]
+	}
+	f := c.buffer[0]
+	c.buffer = c.buffer[1:]
+	return f, true
+}
+
+func (c *SpecClient) Close() { c.conn.Close() }
+
+// watchGame connects to the lobby address as a spectator.
+func (g *Game) watchGame() {
+	c, err := DialSpectate(g.lobby.Addr)
+	if err != nil {
+		g.netStatus = "Can't watch: " + err.Error()
+		return
+	}
+	g.spectator = c
+	g.players.Clear()
+	g.gameState = "spectate"
+}
+
+// stopWatching leaves spectator mode for the lobby.
+func (g *Game) stopWatching(msg string) {
+	g.spectator.Close()
+	g.spectator = nil
+	g.mode = gameModes[g.modeIdx]
+	g.Reset()
+	g.netStatus = msg
+	g.gameState = "lobby"
+}
+
+func (g *Game) updateSpectate(ticks int) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.stopWatching("")
+		return
+	}
+	for range ticks {
+		f, open := g.spectator.Next()
+		if !open {
+			g.stopWatching("Stream ended")
+			return
+		}
+		if f != nil {
+			g.applySpecFrame(f)
+		}
+		g.updateBackground()
+		g.updateEffects()
+		g.updateCamera()
+	}
+}
+
+// drawSpectateHUD labels the screen as a spectator view.
+func (g *Game) drawSpectateHUD(screen *ebiten.Image) {
+	if g.spectator == nil {
+		return
+	}
+	drawCentered := func(line string, y float64, clr color.Color) {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(screenWidth)/2-float64(len(line))*4, y)
+		op.ColorScale.ScaleWithColor(clr)
+		text.Draw(screen, line, fontFace, op)
+	}
+	drawCentered("SPECTATING  [Esc] leave", float64(screenHeight)-44, color.RGBA{160, 160, 160, 255})
+
+	var lines []string
+	if !g.spectator.playing {
+		lines = []string{"Buffering..."}
+	} else if g.runOver {
+		lines = []string{g.endTitle, fmt.Sprintf("Final score: %d", g.score)}
+	}
+	for i, line := range lines {
+		drawCentered(line, float64(screenHeight)/2-20+float64(i)*20, color.White)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// nextFrame polls the client until it hands out a frame or the stream ends.
+func nextFrame(t *testing.T, c *SpecClient) (*SpecFrame, bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if f, open := c.Next(); f != nil || !open {
+			return f, open
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timed out waiting for a frame")
+	return nil, false
+}
+
+func TestSpectateStream(t *testing.T) {
+	srv, err := ListenSpectators("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	c, err := DialSpectate(srv.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for deadline := time.Now().Add(5 * time.Second); srv.Count() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("server never saw the spectator")
+		}
+	}
+
+	const frames = specDelay + 20
+	send := func(from, to int) {
+		for i := from; i < to; i++ {
+			srv.Broadcast(&SpecFrame{Frame: i, Rate: defaultSimRate, Score: i * 10})
+		}
+	}
+
+	// Short of the delay, the client only buffers
+	send(0, specDelay-1)
+	for deadline := time.Now().Add(5 * time.Second); len(c.buffer) < specDelay-1; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d frames arrived", len(c.buffer), specDelay-1)
+		}
+		if f, _ := c.Next(); f != nil {
+			t.Fatalf("frame %d played before %d were buffered", f.Frame, specDelay)
+		}
+	}
+
+	send(specDelay-1, frames)
+	for want := 0; want < frames; want++ {
+		f, open := nextFrame(t, c)
+		if !open {
+			t.Fatalf("stream closed before frame %d", want)
+		}
+		if f.Frame != want || f.Score != want*10 || f.Rate != defaultSimRate {
+			t.Fatalf("got frame %d (score %d, rate %d), want frame %d", f.Frame, f.Score, f.Rate, want)
+		}
+	}
+
+	srv.Close()
+	if f, open := nextFrame(t, c); open {
+		t.Errorf("got frame %d after the server closed", f.Frame)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// --- Sprites ---
+
+// spriteDir holds the game's art. Everything in it is optional: any sprite
+// that isn't found is drawn as the old solid-colored rectangle.
+//
+//	assets/atlas.png   texture atlas
+//	assets/atlas.json  sprite rectangles and animations within the atlas
+//	assets/sprites/    single PNGs, named after the sprite (e.g. grunt.png)
+var spriteDir = "assets"
+
+// Sprite is a region of a texture. Fallback sprites are white, so drawing
+// code tints them with the entity's color.
+type Sprite struct {
+	Image    *ebiten.Image
+	Fallback bool
+}
+
+// Animation plays a run of sprites at a fixed rate.
+type Animation struct {
+	Frames []*Sprite
+	FPS    int  // frames per second
+	Loop   bool // otherwise it holds on the last frame
+}
+
+// Frame returns the sprite to show tick ticks into the animation, when ticks
+// come rate times a second.
+func (a *Animation) Frame(tick, rate int) *Sprite {
+	i := tick * a.FPS / rate
+	if a.Loop {
+		i %= len(a.Frames)
+	} else if i >= len(a.Frames) {
+		i = len(a.Frames) - 1
+	}
+	return a.Frames[i]
+}
+
+var (
+	sprites    = map[string]*Sprite{}
+	animations = map[string]*Animation{}
+
+	whiteImage = func() *ebiten.Image {
+		img := ebiten.NewImage(3, 3)
+		img.Fill(color.White)
+		return img
+	}()
+	// fallbackSprite is a white pixel, scaled and tinted to stand in for any
+	// missing art.
+	fallbackSprite = &Sprite{Image: whiteImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image), Fallback: true}
+)
+
+// atlasFile is the layout of assets/atlas.json:
+//
+//	{
+//	  "image": "atlas.png",
+//	  "sprites": {"player_idle_0": {"x": 0, "y": 0, "w": 32, "h": 32}},
+//	  "animations": {"player_idle": {"frames": ["player_idle_0", "player_idle_1"], "fps": 8, "loop": true}}
+//	}
+type atlasFile struct {
+	Image   string `json:"image"`
+	Sprites map[string]struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"sprites"`
+	Animations map[string]struct {
+		Frames []string `json:"frames"`
+		FPS    int      `json:"fps"`
+		Loop   bool     `json:"loop"`
+	} `json:"animations"`
+}
+
+func loadPNG(path string) (*ebiten.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return ebiten.NewImageFromImage(img), nil
+}
+
+// loadSprites reads the atlas and any single-sprite PNGs. Missing or broken
+// files are skipped.
+func loadSprites() {
+	if data, err := os.ReadFile(filepath.Join(spriteDir, "atlas.json")); err == nil {
+		var atlas atlasFile
+		if json.Unmarshal(data, &atlas) == nil && atlas.Image != "" {
+			if sheet, err := loadPNG(filepath.Join(spriteDir, atlas.Image)); err == nil {
+				for name, r := range atlas.Sprites {
+					rect := image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+					sprites[name] = &Sprite{Image: sheet.SubImage(rect).(*ebiten.Image)}
+				}
+			}
+			for name, a := range atlas.Animations {
+				anim := &Animation{Loop: a.Loop, FPS: max(1, min(60, a.FPS))}
+				for _, frame := range a.Frames {
+					if s, ok := sprites[frame]; ok {
+						anim.Frames = append(anim.Frames, s)
+					}
+				}
+				if len(anim.Frames) > 0 {
+					animations[name] = anim
+				}
+			}
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(spriteDir, "sprites", "*.png"))
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".png")
+		if _, ok := sprites[name]; ok {
+			continue // The atlas wins
+		}
+		if img, err := loadPNG(path); err == nil {
+			sprites[name] = &Sprite{Image: img}
+		}
+	}
+}
+
+// spriteFrame resolves name at tick, with rate ticks a second: an animation
+// if there is one, then a single sprite, then the fallback rectangle.
+func spriteFrame(name string, tick, rate int) *Sprite {
+	if a, ok := animations[name]; ok {
+		return a.Frame(tick, rate)
+	}
+	if s, ok := sprites[name]; ok {
+		return s
+	}
+	return fallbackSprite
+}
+
+// hasSprite reports whether there is art (not a fallback) for name.
+func hasSprite(name string) bool {
+	_, anim := animations[name]
+	_, single := sprites[name]
+	return anim || single
+}
+
+// drawSprite draws s stretched over the w x h box at (x, y), rotated by angle
+// radians about its center. Fallback sprites are filled with tint and never
+// rotated; real art is only tinted if tintArt is set.
+func drawSprite(screen *ebiten.Image, s *Sprite, x, y, w, h, angle float64, tint color.Color, tintArt bool) {
+	b := s.Image.Bounds()
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(w/float64(b.Dx()), h/float64(b.Dy()))
+	if angle != 0 && !s.Fallback {
+		op.GeoM.Translate(-w/2, -h/2)
+		op.GeoM.Rotate(angle)
+		op.GeoM.Translate(w/2, h/2)
+	}
+	op.GeoM.Translate(x, y)
+	if s.Fallback || tintArt {
+		op.ColorScale.ScaleWithColor(tint)
+	}
+	screen.DrawImage(s.Image, op)
+}
+
+// --- Entity Sprites ---
+
+var enemyBulletColor = color.RGBA{0, 255, 255, 255}
+
+// bulletAngle turns sprites drawn pointing down the screen to face along the
+// bullet's velocity.
+func bulletAngle(vx, vy float64) float64 {
+	return math.Atan2(vy, vx) - math.Pi/2
+}
+
+// playerAnim picks the player's animation from their movement this tick.
+func playerAnim(in Input) string {
+	switch {
+	case in.Left && !in.Right:
+		return "player_left"
+	case in.Right && !in.Left:
+		return "player_right"
+	}
+	return "player_idle"
+}
+
+func (g *Game) drawPlayers(screen *ebiten.Image) {
+	rate := g.simRate()
+	for i, p := range g.players.Items {
+		if p.Out {
+			continue
+		}
+		// Blink while invulnerable
+		if p.Invuln == 0 || (p.Invuln/g.ticks(4))%2 == 0 {
+			x, y := p.Lerp(g.clock.Alpha)
+			if hasSprite("thruster") {
+				th := p.Size / 2
+				drawSprite(screen, spriteFrame("thruster", g.elapsedFrames, rate), x+p.Size/4, y-th+2, p.Size/2, th, 0, color.White, false)
+			}
+			anim := playerAnim(g.lastInputs[i])
+			// Player 2 uses their own art if there is any, else player 1's tinted
+			tintArt := false
+			if p.Index > 0 {
+				if hasSprite("p2_" + anim) {
+					anim = "p2_" + anim
+				} else {
+					tintArt = true
+				}
+			}
+			drawSprite(screen, spriteFrame(anim, g.elapsedFrames, rate), x, y, p.Size, p.Size, 0, p.Color, tintArt)
+		}
+	}
+}
+
+// drawEntities draws everything with a Visual on layer.
+func (g *Game) drawEntities(screen *ebiten.Image, layer int) {
+	for _, s := range g.stores() {
+		for i := range s.Len() {
+			if e := s.At(i).Parts(); e.Visual != nil && e.Visual.Layer == layer {
+				g.drawEntity(screen, e)
+			}
+		}
+	}
+}
+
+// drawGlowing draws everything whose Visual glows, for the bloom pass.
+func (g *Game) drawGlowing(screen *ebiten.Image) {
+	for _, s := range g.stores() {
+		for i := range s.Len() {
+			if e := s.At(i).Parts(); e.Visual != nil && e.Visual.Glow {
+				g.drawEntity(screen, e)
+			}
+		}
+	}
+}
+
+func (g *Game) drawEntity(screen *ebiten.Image, e Parts) {
+	t, v := e.Transform, e.Visual
+	tick := g.elapsedFrames
+	if l := e.Life; l != nil {
+		tick = l.Age
+		// Blink during the last two seconds before it expires
+		if v.Blink && l.Max > 0 && l.Age > g.ticks(l.Max-120) && (l.Age/g.ticks(8))%2 == 0 {
+			return
+		}
+	}
+	name, tint := v.Sprite, color.Color(v.Tint)
+	if e.Health != nil && e.Health.Flash > 0 {
+		name, tint = v.Sprite+"_flash", color.White
+	}
+	angle := 0.0
+	if v.Rotate && e.Velocity != nil {
+		angle = bulletAngle(e.Velocity.SpeedX, e.Velocity.SpeedY)
+	}
+	x, y := t.Lerp(g.clock.Alpha)
+	drawSprite(screen, spriteFrame(name, tick+v.Phase, g.simRate()), x, y, t.Size, t.Size, angle, tint, false)
+	if v.Label != "" && !hasSprite(v.Sprite) {
+		labelOp := &text.DrawOptions{}
+		labelOp.GeoM.Translate(x+4, y)
+		labelOp.ColorScale.ScaleWithColor(color.Black)
+		text.Draw(screen, v.Label, fontFace, labelOp)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "fmt"
+
+// --- Run Stats ---
+
+// RunStats counts what happened during a single run. It is shown on the
+// death card.
+type RunStats struct {
+	Frames           int `json:"frames"`
+	Rate             int `json:"rate,omitempty"` // ticks per second Frames were counted at
+	ShotsFired       int `json:"shots_fired"`
+	Kills            int `json:"kills"`
+	HitsTaken        int `json:"hits_taken"`
+	PickupsCollected int `json:"pickups_collected"`
+	BombsUsed        int `json:"bombs_used"`
+	Grazes           int `json:"grazes"`
+	MaxCombo         int `json:"max_combo"`
+}
+
+// Accuracy is kills per shot fired, as a percentage.
+func (s RunStats) Accuracy() float64 {
+	if s.ShotsFired == 0 {
+		return 0
+	}
+	return float64(s.Kills) / float64(s.ShotsFired) * 100
+}
+
+// TickRate is the sim rate the run was played at. Runs saved before the rate
+// was recorded were all at the default.
+func (s RunStats) TickRate() int {
+	if s.Rate <= 0 {
+		return defaultSimRate
+	}
+	return s.Rate
+}
+
+// Lines formats the stats for the death card.
+func (s RunStats) Lines() []string {
+	secs := s.Frames / s.TickRate()
+	return []string{
+		fmt.Sprintf("Kills: %d  Shots: %d  Acc: %.0f%%", s.Kills, s.ShotsFired, s.Accuracy()),
+		fmt.Sprintf("Time: %d:%02d  Bombs: %d  Pickups: %d", secs/60, secs%60, s.BombsUsed, s.PickupsCollected),
+		fmt.Sprintf("Best Combo: %d  Grazes: %d", s.MaxCombo, s.Grazes),
+	}
+}
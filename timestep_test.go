@@ -0,0 +1,30 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimRateKeepsGameSpeed(t *testing.T) {
+	for i, rate := range simRates {
+		g := newTestGame(1)
+		g.simRateIdx = i
+		p := g.players.Items[0]
+		startX := p.X
+		var in [maxPlayers]Input
+		in[0].Right = true
+		for range rate { // One second
+			g.step(in)
+		}
+		want := g.difficulty.PlayerSpeed * defaultSimRate
+		if moved := p.X - startX; math.Abs(moved-want) > 1e-6 {
+			t.Errorf("%d Hz: moved %.2f px in a second, want %.2f", rate, moved, want)
+		}
+		if secs := g.seconds(g.elapsedFrames); secs != 1 {
+			t.Errorf("%d Hz: clock reads %vs, want 1s", rate, secs)
+		}
+		if got, want := g.ticks(pickupLifetime), pickupLifetime*rate/defaultSimRate; got != want {
+			t.Errorf("%d Hz: pickup lifetime %d ticks, want %d", rate, got, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// --- UI Helpers ---
+
+var buttonColor = color.RGBA{60, 60, 120, 200}
+
+// fillRect fills a rectangle by stretching the shared white pixel, so panels
+// and buttons don't create a texture every frame.
+func fillRect(screen *ebiten.Image, x, y, w, h float64, clr color.Color) {
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(w, h)
+	op.GeoM.Translate(x, y)
+	op.ColorScale.ScaleWithColor(clr)
+	screen.DrawImage(fallbackSprite.Image, op)
+}
+
+// drawButton draws a filled button with its label centered.
+func drawButton(screen *ebiten.Image, x, y, w, h float64, label string) {
+	fillRect(screen, x, y, w, h, buttonColor)
+
+	labelWidth := float64(len(label)) * 8
+	labelOp := &text.DrawOptions{}
+	labelOp.GeoM.Translate(x+w/2-labelWidth/2, y+h/2-10)
+	text.Draw(screen, label, fontFace, labelOp)
+}
+
+// drawSlider draws a bar filled to v (0-1) with the percentage on it.
+func drawSlider(screen *ebiten.Image, x, y, w, h, v float64) {
+	fillRect(screen, x, y, w, h, color.RGBA{30, 30, 60, 200})
+	fillRect(screen, x, y, w*v, h, buttonColor)
+	label := fmt.Sprintf("%d%%", int(math.Round(v*100)))
+	labelOp := &text.DrawOptions{}
+	labelOp.GeoM.Translate(x+w/2-float64(len(label))*4, y+h/2-10)
+	text.Draw(screen, label, fontFace, labelOp)
+}
+
+// sliderAt is the value of a slider w wide at x under the cursor.
+func sliderAt(x, w float64) float64 {
+	cx, _ := ebiten.CursorPosition()
+	return max(0, min(1, (float64(cx)-x)/w))
+}
+
+// clickedIn reports whether the left mouse button was just pressed inside the
+// given rectangle.
+func clickedIn(x, y, w, h float64) bool {
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return false
+	}
+	cx, cy := ebiten.CursorPosition()
+	xf, yf := float64(cx), float64(cy)
+	return xf >= x && xf <= x+w && yf >= y && yf <= y+h
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// --- Weapons ---
+
+type WeaponType int
+
+const (
+	WeaponBlaster WeaponType = iota
+	WeaponSpread
+	WeaponLaser
+	WeaponHoming
+	WeaponPiercer
+	WeaponRearGun
+	numWeaponTypes
+)
+
+const maxWeaponLevel = 3
+
+type weaponInfo struct {
+	Name     string
+	FireRate int // ticks between shots
+	Damage   int
+	Speed    float64
+	Size     float64
+	Color    color.RGBA
+	Sprite   string // bullet sprite, drawn pointing down the screen
+	Shape    Shape
+}
+
+var weaponInfos = [numWeaponTypes]weaponInfo{
+	WeaponBlaster: {Name: "Blaster", FireRate: 12, Damage: 1, Speed: 8, Size: 6, Color: color.RGBA{255, 255, 0, 255}, Sprite: "bullet_blaster", Shape: ShapeCircle},
+	WeaponSpread:  {Name: "Spread", FireRate: 18, Damage: 1, Speed: 7, Size: 6, Color: color.RGBA{255, 160, 0, 255}, Sprite: "bullet_spread", Shape: ShapeCircle},
+	WeaponLaser:   {Name: "Laser", FireRate: 3, Damage: 1, Speed: 14, Size: 4, Color: color.RGBA{255, 80, 255, 255}, Sprite: "bullet_laser", Shape: ShapeRotRect},
+	WeaponHoming:  {Name: "Homing", FireRate: 24, Damage: 2, Speed: 5, Size: 8, Color: color.RGBA{120, 255, 120, 255}, Sprite: "bullet_homing", Shape: ShapeRotRect},
+	WeaponPiercer: {Name: "Piercer", FireRate: 16, Damage: 2, Speed: 10, Size: 6, Color: color.RGBA{255, 255, 255, 255}, Sprite: "bullet_piercer", Shape: ShapeRotRect},
+	WeaponRearGun: {Name: "Rear Gun", FireRate: 12, Damage: 1, Speed: 8, Size: 6, Color: color.RGBA{255, 200, 120, 255}, Sprite: "bullet_rear", Shape: ShapeCircle},
+}
+
+func (t WeaponType) String() string {
+	if t < 0 || t >= numWeaponTypes {
+		return "unknown"
+	}
+	return weaponInfos[t].Name
+}
+
+type Weapon struct {
+	Type  WeaponType
+	Level int // 1..maxWeaponLevel
+}
+
+func (w Weapon) Info() weaponInfo {
+	return weaponInfos[w.Type]
+}
+
+// rotate turns the vector (x, y) by angle radians.
+func rotate(x, y, angle float64) (float64, float64) {
+	sin, cos := math.Sincos(angle)
+	return x*cos - y*sin, x*sin + y*cos
+}
+
+// Fire adds the bullets for one shot from the muzzle at (x, y) heading along
+// the unit vector (dirX, dirY) to out. The pattern depends on type and level.
+func (w Weapon) Fire(out *Store[Bullet], x, y, dirX, dirY float64) {
+	info := w.Info()
+	shot := func(offset, angle float64) *Bullet {
+		dx, dy := rotate(dirX, dirY, angle)
+		// Offset is sideways relative to the firing direction
+		ox, oy := -dirY*offset, dirX*offset
+		return out.Add(Bullet{
+			Transform: Transform{X: x + ox - info.Size/2, Y: y + oy - info.Size/2, Size: info.Size},
+			Velocity:  Velocity{SpeedX: dx * info.Speed, SpeedY: dy * info.Speed},
+			Hitbox:    Hitbox{Shape: info.Shape},
+			Visual:    Visual{Sprite: info.Sprite, Tint: info.Color, Layer: layerShots, Rotate: true, Glow: true},
+			Damage:    info.Damage,
+			Weapon:    w.Type,
+		})
+	}
+	deg := math.Pi / 180
+
+	switch w.Type {
+	case WeaponBlaster:
+		switch w.Level {
+		case 1:
+			shot(0, 0)
+		case 2:
+			shot(-6, 0)
+			shot(6, 0)
+		default:
+			shot(-10, 0)
+			shot(0, 0)
+			shot(10, 0)
+		}
+	case WeaponSpread:
+		ways := 1 + 2*w.Level // 3, 5, 7
+		for i := 0; i < ways; i++ {
+			shot(0, float64(i-ways/2)*12*deg)
+		}
+	case WeaponLaser:
+		b := shot(0, 0)
+		b.Size += float64(w.Level-1) * 2
+		b.Pierce = w.Level
+	case WeaponHoming:
+		for i := 0; i < w.Level; i++ {
+			b := shot((float64(i)-float64(w.Level-1)/2)*12, float64(i*2-(w.Level-1))*20*deg)
+			b.Homing = true
+		}
+	case WeaponPiercer:
+		b := shot(0, 0)
+		b.Pierce = 2 * w.Level
+	case WeaponRearGun:
+		shot(0, 0)
+		shot(0, math.Pi)
+		if w.Level >= 2 {
+			shot(0, math.Pi-25*deg)
+			shot(0, math.Pi+25*deg)
+		}
+		if w.Level >= 3 {
+			shot(-8, 0)
+			shot(8, 0)
+		}
+	}
+}
+
+// CurrentWeapon returns the selected weapon at its upgrade level.
+func (p *Player) CurrentWeapon() Weapon {
+	return Weapon{Type: p.Weapon, Level: p.WeaponLevels[p.Weapon]}
+}
+
+// CycleWeapon switches to the next weapon type.
+func (p *Player) CycleWeapon() {
+	p.Weapon = (p.Weapon + 1) % numWeaponTypes
+	p.FireCooldown = 0
+}
+
+// UpgradeWeapon raises the current weapon's level, up to maxWeaponLevel.
+func (p *Player) UpgradeWeapon() {
+	if p.WeaponLevels[p.Weapon] < maxWeaponLevel {
+		p.WeaponLevels[p.Weapon]++
+	}
+}
+
+// fireWeapon spawns the player's current weapon pattern heading along
+// (dirX, dirY) and starts the cooldown.
+func (g *Game) fireWeapon(p *Player, dirX, dirY float64) {
+	w := p.CurrentWeapon()
+	mx, my := p.Muzzle(dirX, dirY)
+	first := g.bullets.Len()
+	w.Fire(&g.bullets, mx, my, dirX, dirY)
+	if p.Effects[PickupSpread] > 0 && g.bullets.Len() > first {
+		// Spread shot pickup flanks the main shot with two angled copies
+		for _, angle := range [2]float64{-0.35, 0.35} {
+			b := g.bullets.Add(*g.bullets.Items[first])
+			b.SpeedX, b.SpeedY = rotate(b.SpeedX, b.SpeedY, angle)
+		}
+	}
+	for _, b := range g.bullets.Items[first:] {
+		b.Owner = p.Index
+	}
+	g.playSound("shoot")
+	g.stats.ShotsFired++
+	p.Stats.ShotsFired++
+	cooldown := w.Info().FireRate
+	if p.Effects[PickupRapidFire] > 0 {
+		cooldown /= 2
+	}
+	p.FireCooldown = g.ticks(max(cooldown, g.mode.MinFireInterval()))
+}
+
+// --- Charge Shot ---
+
+const (
+	chargeMin = 20 // ticks of holding before a release fires a charge shot
+	chargeMax = 90 // ticks to reach full charge
+)
+
+var chargeColor = color.RGBA{120, 220, 255, 255}
+
+// updateTrigger runs one tick of the player's fire controls: the cooldown,
+// hold-to-fire and charging/releasing a charge shot.
+func (g *Game) updateTrigger(p *Player, in Input) {
+	if in.SwapWeapon {
+		p.CycleWeapon()
+	}
+	if p.FireCooldown > 0 {
+		p.FireCooldown--
+	}
+	if !g.mode.AllowFire() {
+		return
+	}
+	if in.Charge {
+		// Normal fire is held back while charging
+		if p.Charge < g.ticks(chargeMax) {
+			p.Charge++
+		}
+		return
+	}
+	dirX, dirY := g.aimDirection(p, in)
+	if p.Charge > 0 {
+		if p.Charge < g.ticks(chargeMin) {
+			p.Charge = 0 // Too short to count
+		} else if p.FireCooldown == 0 {
+			g.fireCharge(p, dirX, dirY)
+			p.Charge = 0
+		}
+		// Otherwise the charge is held until the weapon can fire again
+		return
+	}
+	if in.Fire && p.FireCooldown == 0 {
+		g.fireWeapon(p, dirX, dirY)
+	}
+}
+
+// fireCharge releases a large piercing bullet scaled by how long it was held.
+func (g *Game) fireCharge(p *Player, dirX, dirY float64) {
+	frac := g.chargeLevel(p)
+	size := 10 + 18*frac
+	mx, my := p.Muzzle(dirX, dirY)
+	g.bullets.Add(Bullet{
+		Transform: Transform{X: mx - size/2, Y: my - size/2, Size: size},
+		Velocity:  Velocity{SpeedX: dirX * 9, SpeedY: dirY * 9},
+		Hitbox:    Hitbox{Shape: weaponInfos[p.Weapon].Shape},
+		Visual:    Visual{Sprite: "bullet_charge", Tint: chargeColor, Layer: layerShots, Rotate: true, Glow: true},
+		Damage:    2 + int(6*frac),
+		Pierce:    3 + int(5*frac),
+		Weapon:    p.Weapon,
+		Charged:   true,
+		Owner:     p.Index,
+	})
+	g.playSound("shoot")
+	g.stats.ShotsFired++
+	p.Stats.ShotsFired++
+	p.FireCooldown = g.ticks(max(p.CurrentWeapon().Info().FireRate, g.mode.MinFireInterval()))
+}
+
+// chargeLevel is how full p's charge is, 0-1.
+func (g *Game) chargeLevel(p *Player) float64 {
+	return min(1, float64(p.Charge)/float64(g.ticks(chargeMax)))
+}
+
+// drawChargeMeter draws a bar under each player building a charge.
+func (g *Game) drawChargeMeter(screen *ebiten.Image) {
+	for _, p := range g.players.Items {
+		if !p.Out && p.Charge > 0 {
+			drawPlayerCharge(screen, p, g.clock.Alpha, g.chargeLevel(p), p.Charge >= g.ticks(chargeMin))
+		}
+	}
+}
+
+// drawPlayerCharge draws p's meter filled to level, grey until it's ready to
+// fire.
+func drawPlayerCharge(screen *ebiten.Image, p *Player, alpha, level float64, ready bool) {
+	w := float32(p.Size)
+	px, py := p.Lerp(alpha)
+	x, y := float32(px), float32(py+p.Size+4)
+	vector.DrawFilledRect(screen, x, y, w, 4, color.RGBA{40, 40, 40, 200}, false)
+	fill := chargeColor
+	if !ready {
+		fill = color.RGBA{120, 120, 120, 255}
+	}
+	vector.DrawFilledRect(screen, x, y, w*float32(level), 4, fill, false)
+}
+
+// --- Homing ---
+
+const homingTurnRate = 0.08 // radians per default tick
+
+// steerHoming turns a homing bullet toward the nearest living enemy.
+func (g *Game) steerHoming(b *Bullet) {
+	var target *Enemy
+	best := math.MaxFloat64
+	bx, by := b.X+b.Size/2, b.Y+b.Size/2
+	for _, e := range g.enemies.Items {
+		if e.Gone {
+			continue
+		}
+		dx, dy := e.X+e.Size/2-bx, e.Y+e.Size/2-by
+		if d := dx*dx + dy*dy; d < best {
+			best, target = d, e
+		}
+	}
+	if target == nil {
+		return
+	}
+	want := math.Atan2(target.Y+target.Size/2-by, target.X+target.Size/2-bx)
+	have := math.Atan2(b.SpeedY, b.SpeedX)
+	diff := math.Remainder(want-have, 2*math.Pi)
+	turn := homingTurnRate * g.tickScale()
+	if diff > turn {
+		diff = turn
+	} else if diff < -turn {
+		diff = -turn
+	}
+	b.SpeedX, b.SpeedY = rotate(b.SpeedX, b.SpeedY, diff)
+}
+
+// --- Weapon HUD ---
+
+// drawWeaponHUD shows p's weapon, player 1 bottom-left and player 2
+// bottom-right.
+func (g *Game) drawWeaponHUD(screen *ebiten.Image, p *Player) {
+	if p.Out {
+		return
+	}
+	w := p.CurrentWeapon()
+	swapKey := "Q"
+	if p.Index == 1 {
+		swapKey = ","
+	}
+	line := fmt.Sprintf("%s Lv%d  [%s] swap", w.Info().Name, w.Level, swapKey)
+	if g.autoFire && p.Index == 0 {
+		line += "  AUTO [F]"
+	}
+	x := 10.0
+	if p.Index == 1 {
+		x = float64(screenWidth) - float64(len(line))*8 - 10
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, float64(screenHeight)-26)
+	op.ColorScale.ScaleWithColor(w.Info().Color)
+	text.Draw(screen, line, fontFace, op)
+}
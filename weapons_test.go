@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestOneShotModeLimitsChargeShots(t *testing.T) {
+	for i, rate := range simRates {
+		g := newTestGame(1)
+		g.simRateIdx = i
+		g.mode = oneShotMode{}
+		gap := g.ticks(oneShotMode{}.MinFireInterval())
+		cycle := g.ticks(chargeMin) + 1 // Charge just long enough, then let go
+		last, charged := -gap, 0
+		for tick := range 10 * rate {
+			var in [maxPlayers]Input
+			in[0].Charge = tick%(cycle+1) < cycle
+			in[0].Fire = true
+			shots := g.stats.ShotsFired
+			g.playerSystem(in)
+			if g.stats.ShotsFired == shots {
+				continue
+			}
+			if tick-last < gap {
+				t.Fatalf("%d Hz: shots %d ticks apart, want at least %d", rate, tick-last, gap)
+			}
+			last = tick
+			if b := g.bullets.Items[g.bullets.Len()-1]; b.Charged {
+				charged++
+			}
+		}
+		if charged == 0 {
+			t.Errorf("%d Hz: no charge shots fired", rate)
+		}
+	}
+}